@@ -6,42 +6,20 @@ package apns
 
 import (
 	"crypto/ecdsa"
-	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/pascaldekloe/jwt"
-	"golang.org/x/crypto/pkcs12"
 )
 
-func LoadCertificateFromFile(filePath string, password string) (tls.Certificate, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return tls.Certificate{}, err
-	}
-
-	data, err := ioutil.ReadAll(f)
-	if err != nil {
-		return tls.Certificate{}, err
-	}
-
-	key, cert, err := pkcs12.Decode(data, password)
-	if err != nil {
-		return tls.Certificate{}, err
-	}
-
-	return tls.Certificate{
-		Certificate: [][]byte{
-			cert.Raw,
-		},
-		PrivateKey: key,
-	}, nil
-}
-
 func LoadKeyFromFile(filePath string) (*ecdsa.PrivateKey, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
@@ -94,3 +72,32 @@ func GenerateJWTFromKeyFile(keyFile string, keyId string, teamId string, issuedA
 
 	return GenerateJWTFromKey(key, keyId, teamId, issuedAt, expiresAfter)
 }
+
+// DecodeJWT returns the header and claims of a provider token without
+// verifying its signature.
+func DecodeJWT(token string) (map[string]interface{}, map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, errors.New("malformed JWT: expected 3 parts")
+	}
+
+	header := make(map[string]interface{})
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, nil, fmt.Errorf("malformed JWT header: %s", err)
+	}
+
+	claims := make(map[string]interface{})
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, nil, fmt.Errorf("malformed JWT claims: %s", err)
+	}
+
+	return header, claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"context"
+	"sync"
+)
+
+// Notification is a single notification addressed to a device, used with
+// SendBatch.
+type Notification struct {
+	DeviceToken string
+	Headers     Headers
+	Content     []byte
+}
+
+// SendMany sends the same notification to each of the given devices.
+func (c *client) SendMany(ctx context.Context, deviceTokens []string, headers Headers, content []byte) []*SendResult {
+	notifications := make([]Notification, len(deviceTokens))
+	for i, deviceToken := range deviceTokens {
+		notifications[i] = Notification{
+			DeviceToken: deviceToken,
+			Headers:     headers,
+			Content:     content,
+		}
+	}
+
+	return c.SendBatch(ctx, notifications)
+}
+
+// SendBatch sends the given notifications concurrently over the client's
+// connection. The returned results are in the same order as notifications;
+// a notification that could not be sent has a result with Err set.
+func (c *client) SendBatch(ctx context.Context, notifications []Notification) []*SendResult {
+	c.mu.Lock()
+	concurrency := c.concurrency
+	c.mu.Unlock()
+
+	results := make([]*SendResult, len(notifications))
+	slots := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i := range notifications {
+		notification := notifications[i]
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = &SendResult{DeviceToken: notification.DeviceToken, Err: ctx.Err()}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result, err := c.SendContext(ctx, notification.DeviceToken, notification.Headers, notification.Content)
+			if err != nil {
+				result = &SendResult{DeviceToken: notification.DeviceToken, Err: err}
+			}
+			results[i] = result
+		}(i)
+	}
+
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,339 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// LoadCertificateFromFile loads a client certificate and its private key
+// from a PKCS#12 file or a PEM file containing both.
+func LoadCertificateFromFile(filePath string, password string) (tls.Certificate, error) {
+	return LoadCertificateFromFiles(filePath, "", password)
+}
+
+// LoadCertificateFromFiles loads a client certificate from certFile and its
+// private key from keyFile, or from certFile as well if keyFile is empty.
+// The password decrypts a PKCS#12 file or an encrypted PEM key.
+func LoadCertificateFromFiles(certFile string, keyFile string, password string) (tls.Certificate, error) {
+	certData, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	var keyData []byte
+	if keyFile != "" {
+		if keyData, err = ioutil.ReadFile(keyFile); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return LoadCertificate(certData, keyData, password)
+}
+
+// LoadCertificate parses a client certificate, detecting whether certData is
+// PKCS#12 or PEM. A PEM certData may hold the private key and any
+// intermediate certificates as well; otherwise keyData holds the PEM key.
+// The returned certificate includes the full chain, leaf first.
+func LoadCertificate(certData []byte, keyData []byte, password string) (tls.Certificate, error) {
+	var blocks []*pem.Block
+
+	if isPEM(certData) {
+		blocks = append(decodePEMBlocks(certData), decodePEMBlocks(keyData)...)
+	} else {
+		var err error
+		if blocks, err = pkcs12.ToPEM(certData, password); err == pkcs12.ErrIncorrectPassword {
+			return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 certificate: %s", err)
+		} else if err != nil {
+			// Only the legacy PKCS#12 encryption is supported; newer OpenSSL
+			// versions use AES by default.
+			return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 certificate: %s "+
+				"(export it with 'openssl pkcs12 -export -legacy', or use PEM files)", err)
+		}
+		blocks = append(blocks, decodePEMBlocks(keyData)...)
+	}
+
+	var certs []*x509.Certificate
+	var key crypto.PrivateKey
+
+	for _, block := range blocks {
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return tls.Certificate{}, err
+			}
+			certs = append(certs, cert)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY") && key == nil:
+			var err error
+			if key, err = parsePrivateKeyBlock(block, password); err != nil {
+				return tls.Certificate{}, err
+			}
+		}
+	}
+
+	if len(certs) == 0 {
+		return tls.Certificate{}, errors.New("no certificate found")
+	}
+	if key == nil {
+		return tls.Certificate{}, errors.New("no private key found")
+	}
+
+	leaf, err := matchingCertificate(certs, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	chain := [][]byte{leaf.Raw}
+	for _, cert := range certs {
+		if cert != leaf {
+			chain = append(chain, cert.Raw)
+		}
+	}
+
+	return tls.Certificate{
+		Certificate: chain,
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func isPEM(data []byte) bool {
+	return bytes.Contains(data, []byte("-----BEGIN "))
+}
+
+func decodePEMBlocks(data []byte) []*pem.Block {
+	var blocks []*pem.Block
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return blocks
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// parsePrivateKeyBlock decrypts and parses a private key. The block type is
+// not trusted to identify the encoding: pkcs12.ToPEM labels PKCS#1 and EC
+// keys "PRIVATE KEY".
+func parsePrivateKeyBlock(block *pem.Block, password string) (crypto.PrivateKey, error) {
+	der := block.Bytes
+
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		if password == "" {
+			return nil, errors.New("private key is encrypted but no password was given")
+		}
+
+		var err error
+		if der, err = decryptPKCS8(der, password); err != nil {
+			return nil, err
+		}
+	case x509.IsEncryptedPEMBlock(block):
+		if password == "" {
+			return nil, errors.New("private key is encrypted but no password was given")
+		}
+
+		var err error
+		if der, err = x509.DecryptPEMBlock(block, []byte(password)); err != nil {
+			return nil, ErrIncorrectKeyPassword
+		}
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if block.Type == "ENCRYPTED PRIVATE KEY" || x509.IsEncryptedPEMBlock(block) {
+		// A wrong password occasionally yields valid padding around garbage.
+		return nil, ErrIncorrectKeyPassword
+	}
+	return nil, fmt.Errorf("failed to parse %s", strings.ToLower(block.Type))
+}
+
+// matchingCertificate returns the certificate whose public key belongs to
+// the private key.
+func matchingCertificate(certs []*x509.Certificate, key crypto.PrivateKey) (*x509.Certificate, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawSubjectPublicKeyInfo, publicKey) {
+			return cert, nil
+		}
+	}
+
+	return nil, errors.New("private key does not match any certificate")
+}
+
+// Environments an APNs certificate can be used in.
+const (
+	CertificateEnvironmentSandbox    = "sandbox"
+	CertificateEnvironmentProduction = "production"
+	CertificateEnvironmentBoth       = "both"
+)
+
+var (
+	oidUserId = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+
+	// Extensions Apple adds to APNs certificates.
+	oidDevelopment = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 3, 1}
+	oidProduction  = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 3, 2}
+	oidTopics      = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 3, 6}
+)
+
+// CertificateTopic is a topic an APNs certificate can send to, with the push
+// types it covers (such as app, voip or complication).
+type CertificateTopic struct {
+	Topic     string   `json:"topic" yaml:"topic"`
+	PushTypes []string `json:"push_types,omitempty" yaml:"push_types,omitempty"`
+}
+
+// CertificateInfo describes an APNs client certificate.
+type CertificateInfo struct {
+	Subject     string             `json:"subject" yaml:"subject"`
+	CommonName  string             `json:"common_name" yaml:"common_name"`
+	UID         string             `json:"uid,omitempty" yaml:"uid,omitempty"`
+	Topics      []CertificateTopic `json:"topics,omitempty" yaml:"topics,omitempty"`
+	Environment string             `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Issuer      string             `json:"issuer" yaml:"issuer"`
+	NotBefore   time.Time          `json:"not_before" yaml:"not_before"`
+	NotAfter    time.Time          `json:"not_after" yaml:"not_after"`
+}
+
+// InspectCertificate returns the APNs-specific details of a certificate. The
+// UID is the bundle ID the certificate was issued for; universal push
+// certificates also list their topics in an Apple extension.
+func InspectCertificate(cert *x509.Certificate) (*CertificateInfo, error) {
+	info := &CertificateInfo{
+		Subject:    nameString(cert.Subject),
+		CommonName: cert.Subject.CommonName,
+		Issuer:     nameString(cert.Issuer),
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+	}
+
+	for _, name := range cert.Subject.Names {
+		if name.Type.Equal(oidUserId) {
+			info.UID = fmt.Sprint(name.Value)
+		}
+	}
+
+	var development, production bool
+
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidDevelopment):
+			development = true
+		case ext.Id.Equal(oidProduction):
+			production = true
+		case ext.Id.Equal(oidTopics):
+			topics, err := parseTopics(ext.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid topics extension: %s", err)
+			}
+			info.Topics = topics
+		}
+	}
+
+	switch {
+	case development && production:
+		info.Environment = CertificateEnvironmentBoth
+	case development:
+		info.Environment = CertificateEnvironmentSandbox
+	case production:
+		info.Environment = CertificateEnvironmentProduction
+	}
+
+	return info, nil
+}
+
+// nameString formats a distinguished name, showing the UID attribute APNs
+// certificates carry by name rather than by OID.
+func nameString(name pkix.Name) string {
+	return strings.Replace(name.String(), oidUserId.String()+"=", "UID=", -1)
+}
+
+// parseTopics decodes the topics extension, a sequence in which each topic
+// string is followed by a sequence of the push types it covers.
+func parseTopics(value []byte) ([]CertificateTopic, error) {
+	var elements []asn1.RawValue
+	if _, err := asn1.Unmarshal(value, &elements); err != nil {
+		return nil, err
+	}
+
+	var topics []CertificateTopic
+
+	for _, element := range elements {
+		switch element.Tag {
+		case asn1.TagUTF8String, asn1.TagPrintableString, asn1.TagIA5String:
+			topics = append(topics, CertificateTopic{Topic: string(element.Bytes)})
+		case asn1.TagSequence:
+			if len(topics) == 0 {
+				continue
+			}
+
+			var pushTypes []string
+			if _, err := asn1.Unmarshal(element.FullBytes, &pushTypes); err != nil {
+				return nil, err
+			}
+			topics[len(topics)-1].PushTypes = pushTypes
+		}
+	}
+
+	return topics, nil
+}
+
+// CheckCertificateExpiry returns an error if the certificate's leaf is not
+// valid at the given time.
+func CheckCertificateExpiry(cert tls.Certificate, now time.Time) error {
+	leaf := cert.Leaf
+	if leaf == nil {
+		if len(cert.Certificate) == 0 {
+			return nil
+		}
+
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return err
+		}
+	}
+
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("certificate %q expired on %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+	}
+	if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("certificate %q is not valid until %s", leaf.Subject.CommonName, leaf.NotBefore.Format(time.RFC3339))
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures in testdata share one self-signed certificate and EC key. They
+// were created with:
+//
+//	openssl pkcs12 -export -in cert.pem -inkey key.pem -passout pass:secret \
+//	    -certpbe PBE-SHA1-3DES -keypbe PBE-SHA1-3DES -macalg sha1 -out cert.p12
+//	openssl pkcs8 -topk8 -in key.pem -passout pass:secret \
+//	    -v2 aes-256-cbc -v2prf hmacWithSHA256 -out key-aes256.pem
+//	openssl pkcs8 -topk8 -in key.pem -passout pass:secret \
+//	    -v2 des3 -v2prf hmacWithSHA1 -out key-des3.pem
+//
+// combined.pem is cert.pem followed by key.pem, and other-key.pem is an
+// unrelated key.
+
+const testCommonName = "Apple Push Services: com.example.app"
+
+func TestLoadCertificateFromFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		password string
+		err      string
+	}{
+		{name: "PKCS#12", certFile: "cert.p12", password: "secret"},
+		{name: "combined PEM", certFile: "combined.pem"},
+		{name: "separate PEM", certFile: "cert.pem", keyFile: "key.pem"},
+		{name: "PKCS#8 AES-256", certFile: "cert.pem", keyFile: "key-aes256.pem", password: "secret"},
+		{name: "PKCS#8 3DES", certFile: "cert.pem", keyFile: "key-des3.pem", password: "secret"},
+		{name: "PKCS#12 wrong password", certFile: "cert.p12", password: "wrong", err: "password incorrect"},
+		{name: "PKCS#8 no password", certFile: "cert.pem", keyFile: "key-aes256.pem", err: "no password was given"},
+		{name: "missing key", certFile: "cert.pem", err: "no private key found"},
+		{name: "mismatched key", certFile: "cert.pem", keyFile: "other-key.pem", err: "does not match"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyFile := test.keyFile
+			if keyFile != "" {
+				keyFile = "testdata/" + keyFile
+			}
+
+			cert, err := LoadCertificateFromFiles("testdata/"+test.certFile, keyFile, test.password)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cert.Leaf == nil || cert.Leaf.Subject.CommonName != testCommonName {
+				t.Fatalf("got leaf %v, want %q", cert.Leaf, testCommonName)
+			}
+			if len(cert.Certificate) != 1 {
+				t.Errorf("got chain of %d certificates, want 1", len(cert.Certificate))
+			}
+			if _, ok := cert.PrivateKey.(*ecdsa.PrivateKey); !ok {
+				t.Errorf("got private key %T, want *ecdsa.PrivateKey", cert.PrivateKey)
+			}
+		})
+	}
+}
+
+func TestLoadCertificateIncorrectKeyPassword(t *testing.T) {
+	for _, keyFile := range []string{"key-aes256.pem", "key-des3.pem"} {
+		t.Run(keyFile, func(t *testing.T) {
+			_, err := LoadCertificateFromFiles("testdata/cert.pem", "testdata/"+keyFile, "wrong")
+			if !errors.Is(err, ErrIncorrectKeyPassword) {
+				t.Fatalf("got error %v, want %v", err, ErrIncorrectKeyPassword)
+			}
+		})
+	}
+}
+
+func TestInspectCertificate(t *testing.T) {
+	cert, err := LoadCertificateFromFile("testdata/combined.pem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := InspectCertificate(cert.Leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.CommonName != testCommonName {
+		t.Errorf("got common name %q, want %q", info.CommonName, testCommonName)
+	}
+	if info.UID != "com.example.app" {
+		t.Errorf("got UID %q, want %q", info.UID, "com.example.app")
+	}
+	if info.Environment != CertificateEnvironmentBoth {
+		t.Errorf("got environment %q, want %q", info.Environment, CertificateEnvironmentBoth)
+	}
+
+	want := []CertificateTopic{
+		{Topic: "com.example.app", PushTypes: []string{"app"}},
+		{Topic: "com.example.app.voip", PushTypes: []string{"voip"}},
+	}
+	if !reflect.DeepEqual(info.Topics, want) {
+		t.Errorf("got topics %v, want %v", info.Topics, want)
+	}
+}
+
+func TestParseTopics(t *testing.T) {
+	utf8 := func(s string) asn1.RawValue {
+		return asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte(s)}
+	}
+
+	tests := []struct {
+		name     string
+		elements []interface{}
+		want     []CertificateTopic
+	}{
+		{
+			name:     "topics with push types",
+			elements: []interface{}{utf8("com.example.app"), []string{"app"}, utf8("com.example.app.complication"), []string{"complication"}},
+			want: []CertificateTopic{
+				{Topic: "com.example.app", PushTypes: []string{"app"}},
+				{Topic: "com.example.app.complication", PushTypes: []string{"complication"}},
+			},
+		},
+		{
+			name:     "topic without push types",
+			elements: []interface{}{utf8("com.example.app")},
+			want:     []CertificateTopic{{Topic: "com.example.app"}},
+		},
+		{
+			name:     "push types without topic",
+			elements: []interface{}{[]string{"app"}, utf8("com.example.app")},
+			want:     []CertificateTopic{{Topic: "com.example.app"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := asn1.Marshal(test.elements)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			topics, err := parseTopics(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(topics, test.want) {
+				t.Errorf("got %v, want %v", topics, test.want)
+			}
+		})
+	}
+
+	if _, err := parseTopics([]byte{0x30, 0x05}); err == nil {
+		t.Error("got no error for truncated extension")
+	}
+}
+
+func TestCheckCertificateExpiry(t *testing.T) {
+	cert, err := LoadCertificateFromFile("testdata/combined.pem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		now time.Time
+		err string
+	}{
+		{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{now: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), err: "is not valid until"},
+		{now: time.Date(2120, 1, 1, 0, 0, 0, 0, time.UTC), err: "expired"},
+	}
+
+	for _, test := range tests {
+		err := CheckCertificateExpiry(cert, test.now)
+		if test.err == "" && err != nil {
+			t.Errorf("%s: got error %v", test.now, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: got error %v, want error containing %q", test.now, err, test.err)
+		}
+	}
+}
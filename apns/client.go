@@ -6,13 +6,17 @@ package apns
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 
 	"golang.org/x/net/http2"
 )
@@ -22,78 +26,278 @@ const (
 
 	ProductionEndpoint = "api.push.apple.com"
 	SandboxEndpoint    = "api.sandbox.push.apple.com"
+
+	// DefaultConcurrency is the default maximum number of requests SendMany
+	// and SendBatch keep in flight at once. The server's advertised
+	// MAX_CONCURRENT_STREAMS setting further limits the number of streams
+	// open on the connection.
+	DefaultConcurrency = 100
 )
 
 type Headers map[string]string
 
 type Client interface {
+	Close() error
 	ConfigureCertificateAuth(cert tls.Certificate)
+	ConfigureConcurrency(limit int)
 	ConfigureEndpoint(endpoint string)
+	ConfigureRetryPolicy(policy RetryPolicy)
+	ConfigureRootCAs(rootCAs *x509.CertPool)
 	ConfigureTokenAuth(token string)
+	ConfigureTokenProvider(provider TokenProvider)
+	ConfigureValidation(enabled bool)
 	EnableLogging(writer io.Writer)
+	NewRequest(deviceToken string, headers Headers, content []byte) (*http.Request, error)
 	Send(deviceToken string, headers Headers, content []byte) (*SendResult, error)
+	SendBatch(ctx context.Context, notifications []Notification) []*SendResult
+	SendContext(ctx context.Context, deviceToken string, headers Headers, content []byte) (*SendResult, error)
+	SendMany(ctx context.Context, deviceTokens []string, headers Headers, content []byte) []*SendResult
 }
 
 type client struct {
-	bearerToken string
-	certificate tls.Certificate
-	endpoint    string
-	logWriter   io.Writer
+	certificate   tls.Certificate
+	concurrency   int
+	endpoint      string
+	logWriter     io.Writer
+	logMu         sync.Mutex
+	retryPolicy   RetryPolicy
+	rootCAs       *x509.CertPool
+	tokenProvider TokenProvider
+	validate      bool
+
+	mu   sync.Mutex
+	conn *connection
+}
+
+// connection is an HTTP/2 client and the requests in flight on it. It is
+// replaced when the client's endpoint or certificate changes, and the old one
+// is closed once its requests complete.
+type connection struct {
+	httpClient *http.Client
+	transport  *http2.Transport
+	inFlight   sync.WaitGroup
+}
+
+// close waits for the requests in flight to complete and then closes the
+// connection.
+func (conn *connection) close() {
+	conn.inFlight.Wait()
+	conn.transport.CloseIdleConnections()
 }
 
 func NewClient() Client {
 	return &client{
-		bearerToken: "",
 		certificate: tls.Certificate{},
+		concurrency: DefaultConcurrency,
 		endpoint:    ProductionEndpoint,
 		logWriter:   nil,
+		retryPolicy: DefaultRetryPolicy,
 	}
 }
 
+// Close releases the connection held by the client, waiting for any requests
+// in flight on it to complete. A subsequent Send opens a new connection.
+func (c *client) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	if conn != nil {
+		conn.close()
+	}
+	return nil
+}
+
 func (c *client) ConfigureCertificateAuth(cert tls.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.certificate = cert
+	c.closeConnection()
+}
+
+// ConfigureConcurrency sets the maximum number of requests SendMany and
+// SendBatch keep in flight at once. A limit less than 1 restores the default.
+func (c *client) ConfigureConcurrency(limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if limit < 1 {
+		limit = DefaultConcurrency
+	}
+	c.concurrency = limit
 }
 
 func (c *client) ConfigureEndpoint(endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.endpoint = endpoint
+	c.closeConnection()
 }
 
+func (c *client) ConfigureRetryPolicy(policy RetryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.retryPolicy = policy
+}
+
+// ConfigureRootCAs sets the certificate authorities trusted when verifying
+// the server, such as a local apnstest server. A nil pool restores the
+// system roots.
+func (c *client) ConfigureRootCAs(rootCAs *x509.CertPool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rootCAs = rootCAs
+	c.closeConnection()
+}
+
+// ConfigureTokenAuth authenticates requests with a fixed provider token. Use
+// ConfigureTokenProvider to have the token refreshed automatically.
 func (c *client) ConfigureTokenAuth(token string) {
-	c.bearerToken = token
+	if token == "" {
+		c.ConfigureTokenProvider(nil)
+	} else {
+		c.ConfigureTokenProvider(staticTokenProvider(token))
+	}
+}
+
+func (c *client) ConfigureTokenProvider(provider TokenProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokenProvider = provider
+}
+
+// ConfigureValidation sets whether SendContext checks each notification with
+// Validate first. A notification with validation errors is not sent; a
+// *ValidationError describing them is returned instead.
+func (c *client) ConfigureValidation(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.validate = enabled
 }
 
 func (c *client) EnableLogging(writer io.Writer) {
+	c.logMu.Lock()
+	defer c.logMu.Unlock()
+
 	c.logWriter = writer
 }
 
-func (c *client) Send(deviceToken string, headers Headers, content []byte) (*SendResult, error) {
-	deviceUrl, err := url.Parse(fmt.Sprintf(DeviceEndpointFormat, c.endpoint, deviceToken))
+// NewRequest returns the request the client would send for the notification,
+// including its Authorization header, without opening a connection.
+func (c *client) NewRequest(deviceToken string, headers Headers, content []byte) (*http.Request, error) {
+	c.mu.Lock()
+	endpoint := c.endpoint
+	tokenProvider := c.tokenProvider
+	c.mu.Unlock()
+
+	bearerToken, err := c.getBearerToken(tokenProvider)
 	if err != nil {
 		return nil, err
 	}
 
-	var transport http.RoundTripper
+	return newRequest(endpoint, bearerToken, deviceToken, headers, content)
+}
 
-	if c.certificate.PrivateKey != nil {
-		c.log("* Using client certificate\n")
-		tlsConfig := &tls.Config{
-			Certificates: []tls.Certificate{
-				c.certificate,
-			},
+func (c *client) Send(deviceToken string, headers Headers, content []byte) (*SendResult, error) {
+	return c.SendContext(context.Background(), deviceToken, headers, content)
+}
+
+// SendContext sends a notification to the given device. The request is
+// aborted if ctx is canceled or its deadline expires before the response is
+// received.
+//
+// Failures that the client's retry policy considers transient are retried
+// with backoff until the policy's attempts are exhausted.
+func (c *client) SendContext(ctx context.Context, deviceToken string, headers Headers, content []byte) (*SendResult, error) {
+	c.mu.Lock()
+	policy := c.retryPolicy
+	validate := c.validate
+	c.mu.Unlock()
+
+	if validate {
+		if issues := Validate(headers, content); issues.HasErrors() {
+			return nil, &ValidationError{Issues: issues}
 		}
+	}
 
-		tlsTransport := &http.Transport{
-			TLSClientConfig:   tlsConfig,
-			ForceAttemptHTTP2: true,
+	for attempt := 1; ; attempt++ {
+		if policy.MaxAttempts > 1 {
+			c.logf("* Attempt %d of %d\n", attempt, policy.MaxAttempts)
 		}
 
-		http2.ConfigureTransport(tlsTransport)
-		transport = tlsTransport
-	} else {
-		transport = http.DefaultTransport
+		result, err := c.sendAuthenticated(ctx, deviceToken, headers, content)
+
+		if attempt >= policy.MaxAttempts || !isRetryable(ctx, result, err) {
+			return result, err
+		}
+
+		backoff := policy.backoff(attempt)
+		c.logf("* Retrying in %s\n", backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		}
+	}
+}
+
+// sendAuthenticated sends a single request. If APNs reports the provider
+// token as expired, the token is refreshed and the request is sent once more.
+func (c *client) sendAuthenticated(ctx context.Context, deviceToken string, headers Headers, content []byte) (*SendResult, error) {
+	conn, endpoint, tokenProvider := c.getConnection()
+	defer conn.inFlight.Done()
+	client := conn.httpClient
+
+	bearerToken, err := c.getBearerToken(tokenProvider)
+	if err != nil {
+		return nil, err
 	}
 
-	client := http.Client{Transport: transport}
+	result, err := c.send(ctx, client, endpoint, bearerToken, deviceToken, headers, content)
+
+	if err == nil && tokenProvider != nil &&
+		result.ErrorReason() == ReasonExpiredProviderToken && tokenProvider.Invalidate(bearerToken) {
+		c.log("* Provider token expired, retrying with a new token\n")
+
+		bearerToken, err = c.getBearerToken(tokenProvider)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err = c.send(ctx, client, endpoint, bearerToken, deviceToken, headers, content)
+	}
+
+	return result, err
+}
+
+func (c *client) getBearerToken(tokenProvider TokenProvider) (string, error) {
+	if tokenProvider == nil {
+		return "", nil
+	}
+
+	token, err := tokenProvider.Token()
+	if err != nil {
+		c.logf("* Error generating provider token: %s\n", err)
+		return "", err
+	}
+	return token, nil
+}
+
+func newRequest(endpoint string, bearerToken string, deviceToken string, headers Headers, content []byte) (*http.Request, error) {
+	deviceUrl, err := url.Parse(fmt.Sprintf(DeviceEndpointFormat, endpoint, deviceToken))
+	if err != nil {
+		return nil, err
+	}
 
 	req := &http.Request{
 		Method:     "POST",
@@ -107,8 +311,20 @@ func (c *client) Send(deviceToken string, headers Headers, content []byte) (*Sen
 		req.Header.Set(k, v)
 	}
 
-	if c.bearerToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken))
+	if bearerToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", bearerToken))
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(content))
+	req.ContentLength = int64(len(content))
+
+	return req, nil
+}
+
+func (c *client) send(ctx context.Context, client *http.Client, endpoint string, bearerToken string, deviceToken string, headers Headers, content []byte) (*SendResult, error) {
+	req, err := newRequest(endpoint, bearerToken, deviceToken, headers, content)
+	if err != nil {
+		return nil, err
 	}
 
 	c.log("* Sending request:\n")
@@ -118,13 +334,14 @@ func (c *client) Send(deviceToken string, headers Headers, content []byte) (*Sen
 	}
 	c.logf("> %s\n", content)
 
-	req.Body = ioutil.NopCloser(bytes.NewReader(content))
+	req = req.WithContext(ctx)
 
 	res, err := client.Do(req)
 	if err != nil {
 		c.logf("* Error sending request: %s\n", err)
 		return nil, err
 	}
+	defer res.Body.Close()
 
 	c.logf("* Received response:\n")
 	c.logf("< %s\n", res.Status)
@@ -141,9 +358,10 @@ func (c *client) Send(deviceToken string, headers Headers, content []byte) (*Sen
 	c.logf("< %s\n", responseContent)
 
 	result := &SendResult{
-		content:    responseContent,
-		headers:    res.Header,
-		StatusCode: res.StatusCode,
+		content:     responseContent,
+		headers:     res.Header,
+		DeviceToken: deviceToken,
+		StatusCode:  res.StatusCode,
 	}
 
 	c.log("* Done\n")
@@ -151,7 +369,65 @@ func (c *client) Send(deviceToken string, headers Headers, content []byte) (*Sen
 	return result, nil
 }
 
+// getConnection returns the connection used for all requests, creating it on
+// first use, along with the endpoint and token provider to send with. The
+// connection is kept open and reused until the client is closed or its
+// endpoint or certificate changes. The caller must call inFlight.Done on the
+// connection when its request completes.
+func (c *client) getConnection() (*connection, string, TokenProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		c.conn = c.newConnection()
+	}
+
+	c.conn.inFlight.Add(1)
+	return c.conn, c.endpoint, c.tokenProvider
+}
+
+// newConnection must be called with c.mu held.
+func (c *client) newConnection() *connection {
+
+	tlsConfig := &tls.Config{
+		RootCAs: c.rootCAs,
+	}
+
+	if c.certificate.PrivateKey != nil {
+		c.log("* Using client certificate\n")
+		tlsConfig.Certificates = []tls.Certificate{
+			c.certificate,
+		}
+	}
+
+	transport := &http2.Transport{
+		TLSClientConfig: tlsConfig,
+		DialTLS:         dialTLS,
+		// Queue requests on the open connection rather than dialing new
+		// ones when the server's stream limit is reached.
+		StrictMaxConcurrentStreams: true,
+	}
+
+	return &connection{
+		httpClient: &http.Client{Transport: transport},
+		transport:  transport,
+	}
+}
+
+// closeConnection stops new requests from using the current connection and
+// closes it in the background once its requests in flight complete. It must
+// be called with c.mu held.
+func (c *client) closeConnection() {
+	if c.conn != nil {
+		go c.conn.close()
+	}
+	c.conn = nil
+}
+
 func (c *client) log(text string) {
+	c.logMu.Lock()
+	defer c.logMu.Unlock()
+
 	if c.logWriter != nil {
 		_, _ = io.WriteString(c.logWriter, text)
 	}
@@ -162,28 +438,69 @@ func (c *client) logf(format string, args ...interface{}) {
 }
 
 type SendResult struct {
-	content    []byte
-	headers    http.Header
-	StatusCode int
+	content     []byte
+	headers     http.Header
+	DeviceToken string
+	StatusCode  int
+
+	// Err is set by SendMany and SendBatch when the notification could not be
+	// delivered to APNs at all. StatusCode is 0 in that case.
+	Err error
 }
 
-func (r *SendResult) ErrorReason() string {
-	if r.content != nil && len(r.content) > 0 {
-		var object map[string]interface{}
-
-		_ = json.Unmarshal(r.content, &object)
+// APNsError returns the error describing why APNs rejected the notification,
+// or nil if it was accepted or never reached APNs.
+func (r *SendResult) APNsError() *APNsError {
+	if r.Success() || r.StatusCode == 0 {
+		return nil
+	}
 
-		if reason, ok := object["reason"].(string); ok {
-			return reason
-		}
+	return &APNsError{
+		StatusCode: r.StatusCode,
+		Reason:     r.ErrorReason(),
+		ApnsId:     r.Id(),
+		Timestamp:  r.Timestamp(),
 	}
-	return ""
+}
+
+func (r *SendResult) ErrorReason() ErrorReason {
+	return ErrorReason(r.body().Reason)
 }
 
 func (r *SendResult) Id() string {
 	return r.headers.Get("apns-id")
 }
 
+// UniqueId returns the apns-unique-id APNs assigns to notifications sent to
+// the sandbox environment, which can be looked up in the Push Notifications
+// Console.
+func (r *SendResult) UniqueId() string {
+	return r.headers.Get("apns-unique-id")
+}
+
 func (r *SendResult) Success() bool {
 	return r.StatusCode == 200
 }
+
+// Timestamp returns the time APNs last confirmed the device token was valid
+// for the topic, as reported with a 410 response.
+func (r *SendResult) Timestamp() time.Time {
+	ms := r.body().Timestamp
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+type errorBody struct {
+	Reason    string `json:"reason"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+func (r *SendResult) body() errorBody {
+	var body errorBody
+	if len(r.content) > 0 {
+		_ = json.Unmarshal(r.content, &body)
+	}
+	return body
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brannon/apnstool/apnstest"
+)
+
+func newTestClient(t *testing.T, rules ...apnstest.Rule) (Client, *apnstest.Server) {
+	server := apnstest.NewServer()
+	server.Rules = rules
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient()
+	client.ConfigureEndpoint(server.Addr)
+	client.ConfigureRootCAs(server.RootCAs())
+	client.ConfigureTokenProvider(NewKeyTokenProvider(key, "KEYID", "TEAMID"))
+
+	return client, server
+}
+
+var testHeaders = Headers{"apns-topic": "com.example.app", "apns-push-type": "alert"}
+
+const testDeviceToken = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestClientReconfigureDuringSend(t *testing.T) {
+	client, server := newTestClient(t, apnstest.Rule{Delay: apnstest.Duration(200 * time.Millisecond), Times: 1})
+	defer server.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		result, err := client.Send(testDeviceToken, testHeaders, []byte(`{"aps":{"alert":"hi"}}`))
+		if err == nil && !result.Success() {
+			err = result.APNsError()
+		}
+		done <- err
+	}()
+
+	// Replacing the connection must not interrupt the request in flight on
+	// the old one.
+	time.Sleep(50 * time.Millisecond)
+	client.ConfigureEndpoint(server.Addr)
+
+	if err := <-done; err != nil {
+		t.Fatalf("got error %v for request in flight while reconfiguring", err)
+	}
+
+	result, err := client.Send(testDeviceToken, testHeaders, []byte(`{"aps":{"alert":"hi"}}`))
+	if err != nil || !result.Success() {
+		t.Fatalf("got result %+v and error %v after reconfiguring", result, err)
+	}
+}
+
+func TestClientCloseWaitsForSends(t *testing.T) {
+	client, server := newTestClient(t, apnstest.Rule{Delay: apnstest.Duration(200 * time.Millisecond), Times: 1})
+	defer server.Close()
+
+	var sent time.Time
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := client.Send(testDeviceToken, testHeaders, []byte(`{"aps":{"alert":"hi"}}`)); err != nil {
+			t.Error(err)
+		}
+		sent = time.Now()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	client.Close()
+	closed := time.Now()
+	wg.Wait()
+
+	if closed.Before(sent) {
+		t.Errorf("Close returned %s before the request in flight completed", sent.Sub(closed))
+	}
+}
+
+func TestClientEnableLoggingDuringSends(t *testing.T) {
+	client, server := newTestClient(t)
+	defer server.Close()
+
+	tokens := make([]string, 20)
+	for i := range tokens {
+		tokens[i] = testDeviceToken
+	}
+
+	done := make(chan []*SendResult)
+	go func() {
+		done <- client.SendMany(context.Background(), tokens, testHeaders, []byte(`{"aps":{"alert":"hi"}}`))
+	}()
+
+	var log bytes.Buffer
+	client.EnableLogging(&log)
+	client.EnableLogging(nil)
+
+	for _, result := range <-done {
+		if result.Err != nil || !result.Success() {
+			t.Errorf("got result %+v", result)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrorReason is the reason APNs gives for rejecting a notification.
+type ErrorReason string
+
+const (
+	ReasonBadCollapseId               ErrorReason = "BadCollapseId"
+	ReasonBadDeviceToken              ErrorReason = "BadDeviceToken"
+	ReasonBadExpirationDate           ErrorReason = "BadExpirationDate"
+	ReasonBadMessageId                ErrorReason = "BadMessageId"
+	ReasonBadPriority                 ErrorReason = "BadPriority"
+	ReasonBadTopic                    ErrorReason = "BadTopic"
+	ReasonDeviceTokenNotForTopic      ErrorReason = "DeviceTokenNotForTopic"
+	ReasonDuplicateHeaders            ErrorReason = "DuplicateHeaders"
+	ReasonIdleTimeout                 ErrorReason = "IdleTimeout"
+	ReasonInvalidPushType             ErrorReason = "InvalidPushType"
+	ReasonMissingDeviceToken          ErrorReason = "MissingDeviceToken"
+	ReasonMissingTopic                ErrorReason = "MissingTopic"
+	ReasonPayloadEmpty                ErrorReason = "PayloadEmpty"
+	ReasonTopicDisallowed             ErrorReason = "TopicDisallowed"
+	ReasonBadCertificate              ErrorReason = "BadCertificate"
+	ReasonBadCertificateEnvironment   ErrorReason = "BadCertificateEnvironment"
+	ReasonExpiredProviderToken        ErrorReason = "ExpiredProviderToken"
+	ReasonForbidden                   ErrorReason = "Forbidden"
+	ReasonInvalidProviderToken        ErrorReason = "InvalidProviderToken"
+	ReasonMissingProviderToken        ErrorReason = "MissingProviderToken"
+	ReasonBadPath                     ErrorReason = "BadPath"
+	ReasonMethodNotAllowed            ErrorReason = "MethodNotAllowed"
+	ReasonExpiredToken                ErrorReason = "ExpiredToken"
+	ReasonUnregistered                ErrorReason = "Unregistered"
+	ReasonPayloadTooLarge             ErrorReason = "PayloadTooLarge"
+	ReasonTooManyProviderTokenUpdates ErrorReason = "TooManyProviderTokenUpdates"
+	ReasonTooManyRequests             ErrorReason = "TooManyRequests"
+	ReasonInternalServerError         ErrorReason = "InternalServerError"
+	ReasonServiceUnavailable          ErrorReason = "ServiceUnavailable"
+	ReasonShutdown                    ErrorReason = "Shutdown"
+)
+
+var knownReasons = map[ErrorReason]bool{
+	ReasonBadCollapseId:               true,
+	ReasonBadDeviceToken:              true,
+	ReasonBadExpirationDate:           true,
+	ReasonBadMessageId:                true,
+	ReasonBadPriority:                 true,
+	ReasonBadTopic:                    true,
+	ReasonDeviceTokenNotForTopic:      true,
+	ReasonDuplicateHeaders:            true,
+	ReasonIdleTimeout:                 true,
+	ReasonInvalidPushType:             true,
+	ReasonMissingDeviceToken:          true,
+	ReasonMissingTopic:                true,
+	ReasonPayloadEmpty:                true,
+	ReasonTopicDisallowed:             true,
+	ReasonBadCertificate:              true,
+	ReasonBadCertificateEnvironment:   true,
+	ReasonExpiredProviderToken:        true,
+	ReasonForbidden:                   true,
+	ReasonInvalidProviderToken:        true,
+	ReasonMissingProviderToken:        true,
+	ReasonBadPath:                     true,
+	ReasonMethodNotAllowed:            true,
+	ReasonExpiredToken:                true,
+	ReasonUnregistered:                true,
+	ReasonPayloadTooLarge:             true,
+	ReasonTooManyProviderTokenUpdates: true,
+	ReasonTooManyRequests:             true,
+	ReasonInternalServerError:         true,
+	ReasonServiceUnavailable:          true,
+	ReasonShutdown:                    true,
+}
+
+// IsKnown reports whether the reason is one documented by Apple.
+func (r ErrorReason) IsKnown() bool {
+	return knownReasons[r]
+}
+
+// IsRetryable reports whether a notification rejected for this reason may
+// succeed if sent again unchanged after a delay.
+func (r ErrorReason) IsRetryable() bool {
+	switch r {
+	case ReasonIdleTimeout,
+		ReasonTooManyProviderTokenUpdates,
+		ReasonTooManyRequests,
+		ReasonInternalServerError,
+		ReasonServiceUnavailable,
+		ReasonShutdown:
+		return true
+	}
+	return false
+}
+
+// IsPermanent reports whether a notification rejected for this reason will
+// never succeed without changing the request, its credentials or the device
+// token. ExpiredProviderToken is neither permanent nor retryable: it succeeds
+// once a new provider token is issued.
+func (r ErrorReason) IsPermanent() bool {
+	return r.IsKnown() && !r.IsRetryable() && r != ReasonExpiredProviderToken
+}
+
+// APNsError describes a notification rejected by APNs.
+type APNsError struct {
+	StatusCode int
+	Reason     ErrorReason
+	ApnsId     string
+
+	// Timestamp is the last time APNs confirmed the device token was valid
+	// for the topic. It is only set for 410 responses.
+	Timestamp time.Time
+}
+
+func (e *APNsError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("apns: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("apns: status %d: %s", e.StatusCode, e.Reason)
+}
+
+func (e *APNsError) IsPermanent() bool {
+	return e.Reason.IsPermanent()
+}
+
+func (e *APNsError) IsRetryable() bool {
+	if e.Reason == "" || !e.Reason.IsKnown() {
+		return isRetryableStatus(e.StatusCode)
+	}
+	return e.Reason.IsRetryable()
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case 429, 500, 503:
+		return true
+	}
+	return false
+}
+
+var reasonDescriptions = map[ErrorReason]string{
+	ReasonBadCollapseId:               "The collapse identifier exceeds the maximum allowed size.",
+	ReasonBadDeviceToken:              "The device token is invalid. Verify that the request contains a valid token and that the token matches the environment.",
+	ReasonBadExpirationDate:           "The apns-expiration value is invalid.",
+	ReasonBadMessageId:                "The apns-id value is invalid.",
+	ReasonBadPriority:                 "The apns-priority value is invalid.",
+	ReasonBadTopic:                    "The apns-topic value is invalid.",
+	ReasonDeviceTokenNotForTopic:      "The device token doesn't match the specified topic.",
+	ReasonDuplicateHeaders:            "One or more headers are repeated.",
+	ReasonIdleTimeout:                 "Idle timeout.",
+	ReasonInvalidPushType:             "The apns-push-type value is invalid.",
+	ReasonMissingDeviceToken:          "The device token isn't specified in the request path.",
+	ReasonMissingTopic:                "The apns-topic header is missing and required.",
+	ReasonPayloadEmpty:                "The message payload is empty.",
+	ReasonTopicDisallowed:             "Pushing to this topic is not allowed.",
+	ReasonBadCertificate:              "The certificate is invalid.",
+	ReasonBadCertificateEnvironment:   "The client certificate is for the wrong environment.",
+	ReasonExpiredProviderToken:        "The provider token is stale and a new token should be generated.",
+	ReasonForbidden:                   "The specified action is not allowed.",
+	ReasonInvalidProviderToken:        "The provider token is not valid, or the token signature can't be verified.",
+	ReasonMissingProviderToken:        "No provider certificate was used to connect to APNs, and the authorization header is missing or no provider token is specified.",
+	ReasonBadPath:                     "The request contained an invalid :path value.",
+	ReasonMethodNotAllowed:            "The specified :method value isn't POST.",
+	ReasonExpiredToken:                "The device token has expired.",
+	ReasonUnregistered:                "The device token is inactive for the specified topic.",
+	ReasonPayloadTooLarge:             "The message payload is too large.",
+	ReasonTooManyProviderTokenUpdates: "The provider's authentication token is being updated too often.",
+	ReasonTooManyRequests:             "Too many requests were made consecutively to the same device token.",
+	ReasonInternalServerError:         "An internal server error occurred.",
+	ReasonServiceUnavailable:          "The service is unavailable.",
+	ReasonShutdown:                    "The APNs server is shutting down.",
+}
+
+// Description returns Apple's explanation of the reason, or an empty string
+// for unknown reasons.
+func (r ErrorReason) Description() string {
+	return reasonDescriptions[r]
+}
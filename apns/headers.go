@@ -0,0 +1,32 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import "strings"
+
+// Request headers understood by APNs.
+const (
+	HeaderCollapseId = "apns-collapse-id"
+	HeaderExpiration = "apns-expiration"
+	HeaderId         = "apns-id"
+	HeaderPriority   = "apns-priority"
+	HeaderPushType   = "apns-push-type"
+	HeaderTopic      = "apns-topic"
+)
+
+var knownHeaders = map[string]bool{
+	HeaderCollapseId: true,
+	HeaderExpiration: true,
+	HeaderId:         true,
+	HeaderPriority:   true,
+	HeaderPushType:   true,
+	HeaderTopic:      true,
+}
+
+// IsKnownHeader reports whether name is an apns- request header understood
+// by APNs. The comparison is case-insensitive.
+func IsKnownHeader(name string) bool {
+	return knownHeaders[strings.ToLower(name)]
+}
@@ -4,20 +4,49 @@
 
 package apns
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interruption levels, which control how a notification is presented when a
+// Focus is active (iOS 15 and later).
+const (
+	InterruptionLevelPassive       = "passive"
+	InterruptionLevelActive        = "active"
+	InterruptionLevelTimeSensitive = "time-sensitive"
+	InterruptionLevelCritical      = "critical"
+)
+
+// Live Activity events.
+const (
+	LiveActivityEventStart  = "start"
+	LiveActivityEventUpdate = "update"
+	LiveActivityEventEnd    = "end"
+)
 
 type NotificationBuilder struct {
-	AppId   string
-	content map[string]interface{}
+	AppId    string
+	content  map[string]interface{}
+	err      error
+	headers  Headers
+	pushType string
 }
 
 func NewNotificationBuilder(appId string) *NotificationBuilder {
 	return &NotificationBuilder{
 		AppId:   appId,
 		content: make(map[string]interface{}),
+		headers: make(Headers),
 	}
 }
 
+// aps returns the aps dictionary for the setters, creating it (or replacing a
+// value that is not a dictionary) if necessary.
 func (b *NotificationBuilder) aps() map[string]interface{} {
 	aps, ok := b.content["aps"].(map[string]interface{})
 	if !ok {
@@ -27,6 +56,29 @@ func (b *NotificationBuilder) aps() map[string]interface{} {
 	return aps
 }
 
+// currentAPS returns the aps dictionary without modifying the content. It
+// returns nil if there is no aps dictionary.
+func (b *NotificationBuilder) currentAPS() map[string]interface{} {
+	aps, _ := b.content["aps"].(map[string]interface{})
+	return aps
+}
+
+// alert returns the alert dictionary, converting a plain alert string into
+// the body of the dictionary.
+func (b *NotificationBuilder) alert() map[string]interface{} {
+	aps := b.aps()
+
+	alert, ok := aps["alert"].(map[string]interface{})
+	if !ok {
+		alert = make(map[string]interface{})
+		if text, ok := aps["alert"].(string); ok {
+			alert["body"] = text
+		}
+		aps["alert"] = alert
+	}
+	return alert
+}
+
 func (b *NotificationBuilder) Build() (Headers, []byte, error) {
 	headers, err := b.BuildHeaders()
 	if err != nil {
@@ -41,7 +93,19 @@ func (b *NotificationBuilder) Build() (Headers, []byte, error) {
 	return headers, content, nil
 }
 
+// BuildContent returns the JSON payload, or the first error recorded by a
+// setter given an invalid value.
 func (b *NotificationBuilder) BuildContent() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if b.getPushType() == PushTypeLiveActivity {
+		if err := b.validateLiveActivity(); err != nil {
+			return nil, err
+		}
+	}
+
 	data, err := json.Marshal(b.content)
 	if err != nil {
 		return nil, err
@@ -53,28 +117,77 @@ func (b *NotificationBuilder) BuildContent() ([]byte, error) {
 func (b *NotificationBuilder) BuildHeaders() (Headers, error) {
 	headers := Headers{}
 
-	headers["apns-topic"] = b.AppId
-
 	pushType := b.getPushType()
-	headers["apns-push-type"] = pushType
 
-	if pushType == "background" {
-		headers["apns-priority"] = "5"
+	headers[HeaderTopic] = TopicForPushType(b.AppId, pushType)
+
+	if pushType != "" {
+		headers[HeaderPushType] = pushType
+	}
+
+	if priority, ok := pushTypePriorities[pushType]; ok {
+		headers[HeaderPriority] = priority
+	}
+
+	// Push to Talk notifications must be delivered immediately or not at all.
+	if pushType == PushTypePushToTalk {
+		headers[HeaderExpiration] = "0"
+	}
+
+	for name, value := range b.headers {
+		headers[name] = value
 	}
 
 	return headers, nil
 }
 
 func (b *NotificationBuilder) getPushType() string {
-	aps := b.aps()
-	if hasKey(aps, "alert") || hasKey(aps, "badge") || hasKey(aps, "sound") {
-		return "alert"
-	} else if hasKey(aps, "content-available") && aps["content-available"] == 1 {
-		return "background"
+	if b.pushType != "" {
+		return b.pushType
+	}
+
+	aps := b.currentAPS()
+	if hasKey(aps, "event") {
+		return PushTypeLiveActivity
+	} else if hasKey(aps, "alert") || hasKey(aps, "badge") || hasKey(aps, "sound") {
+		return PushTypeAlert
+	} else if isFlagSet(aps["content-available"]) {
+		return PushTypeBackground
 	}
 	return ""
 }
 
+// validateLiveActivity checks that the fields each Live Activity event needs
+// are present.
+func (b *NotificationBuilder) validateLiveActivity() error {
+	aps := b.currentAPS()
+
+	event, _ := aps["event"].(string)
+	if event == "" {
+		return errors.New("live activity event is required")
+	}
+
+	if !hasKey(aps, "timestamp") {
+		return errors.New("live activity timestamp is required")
+	}
+
+	required := []string{}
+	switch event {
+	case LiveActivityEventStart:
+		required = []string{"content-state", "attributes-type", "attributes"}
+	case LiveActivityEventUpdate:
+		required = []string{"content-state"}
+	}
+
+	for _, key := range required {
+		if !hasKey(aps, key) {
+			return fmt.Errorf("live activity %q event requires %s", event, key)
+		}
+	}
+
+	return nil
+}
+
 func (b *NotificationBuilder) Merge(data map[string]interface{}) *NotificationBuilder {
 	for k, v := range data {
 		b.content[k] = v
@@ -82,8 +195,80 @@ func (b *NotificationBuilder) Merge(data map[string]interface{}) *NotificationBu
 	return b
 }
 
+// SetAlertBody sets the alert text. If no other alert fields are set, the
+// alert is sent in its simple string form.
+func (b *NotificationBuilder) SetAlertBody(body string) *NotificationBuilder {
+	if alert, ok := b.aps()["alert"].(map[string]interface{}); ok {
+		alert["body"] = body
+	} else {
+		b.aps()["alert"] = body
+	}
+	return b
+}
+
+func (b *NotificationBuilder) SetAlertLaunchImage(name string) *NotificationBuilder {
+	b.alert()["launch-image"] = name
+	return b
+}
+
+// SetAlertLocalization sets the key of a localized alert body string in the
+// app's Localizable.strings file, and the arguments to format it with.
+func (b *NotificationBuilder) SetAlertLocalization(key string, args []string) *NotificationBuilder {
+	b.setLocalization("loc-key", key, "loc-args", args)
+	return b
+}
+
+func (b *NotificationBuilder) SetAlertSubtitle(subtitle string) *NotificationBuilder {
+	b.alert()["subtitle"] = subtitle
+	return b
+}
+
+func (b *NotificationBuilder) SetAlertSubtitleLocalization(key string, args []string) *NotificationBuilder {
+	b.setLocalization("subtitle-loc-key", key, "subtitle-loc-args", args)
+	return b
+}
+
+// SetAlertText is equivalent to SetAlertBody.
 func (b *NotificationBuilder) SetAlertText(text string) *NotificationBuilder {
-	b.aps()["alert"] = text
+	return b.SetAlertBody(text)
+}
+
+func (b *NotificationBuilder) SetAlertTitle(title string) *NotificationBuilder {
+	b.alert()["title"] = title
+	return b
+}
+
+func (b *NotificationBuilder) SetAlertTitleLocalization(key string, args []string) *NotificationBuilder {
+	b.setLocalization("title-loc-key", key, "title-loc-args", args)
+	return b
+}
+
+func (b *NotificationBuilder) setLocalization(keyName string, key string, argsName string, args []string) {
+	alert := b.alert()
+	alert[keyName] = key
+	if len(args) > 0 {
+		alert[argsName] = args
+	}
+}
+
+// SetCategory sets the notification type, which selects the actions shown
+// for it. It must match a category registered by the app.
+func (b *NotificationBuilder) SetCategory(category string) *NotificationBuilder {
+	b.aps()["category"] = category
+	return b
+}
+
+// SetAttributes sets the attributes a Live Activity is started with. It is
+// only used with the start event.
+func (b *NotificationBuilder) SetAttributes(attributes map[string]interface{}) *NotificationBuilder {
+	b.aps()["attributes"] = attributes
+	return b
+}
+
+// SetAttributesType sets the name of the app's ActivityAttributes type for a
+// Live Activity start event.
+func (b *NotificationBuilder) SetAttributesType(attributesType string) *NotificationBuilder {
+	b.aps()["attributes-type"] = attributesType
 	return b
 }
 
@@ -102,12 +287,226 @@ func (b *NotificationBuilder) SetContentAvailable(value bool) *NotificationBuild
 	return b
 }
 
+// SetContentState sets the dynamic content of a Live Activity. It must match
+// the app's ContentState type.
+func (b *NotificationBuilder) SetContentState(state map[string]interface{}) *NotificationBuilder {
+	b.aps()["content-state"] = state
+	return b
+}
+
+// SetDismissalDate sets when an ended Live Activity is removed from the Lock
+// Screen.
+func (b *NotificationBuilder) SetDismissalDate(t time.Time) *NotificationBuilder {
+	b.aps()["dismissal-date"] = t.Unix()
+	return b
+}
+
+// SetEvent sets the Live Activity event (start, update or end). A
+// notification with an event is sent with the liveactivity push type.
+func (b *NotificationBuilder) SetEvent(event string) *NotificationBuilder {
+	switch event {
+	case LiveActivityEventStart, LiveActivityEventUpdate, LiveActivityEventEnd:
+	default:
+		b.setError(fmt.Errorf("invalid live activity event %q (must be %s, %s or %s)", event,
+			LiveActivityEventStart, LiveActivityEventUpdate, LiveActivityEventEnd))
+		return b
+	}
+
+	b.aps()["event"] = event
+	return b
+}
+
+// SetFilterCriteria sets the criteria the app's Focus filter uses to decide
+// whether to show the notification.
+func (b *NotificationBuilder) SetFilterCriteria(criteria string) *NotificationBuilder {
+	b.aps()["filter-criteria"] = criteria
+	return b
+}
+
+func (b *NotificationBuilder) SetInterruptionLevel(level string) *NotificationBuilder {
+	switch level {
+	case InterruptionLevelPassive,
+		InterruptionLevelActive,
+		InterruptionLevelTimeSensitive,
+		InterruptionLevelCritical:
+	default:
+		b.setError(fmt.Errorf("invalid interruption level %q (must be %s, %s, %s or %s)", level,
+			InterruptionLevelPassive, InterruptionLevelActive, InterruptionLevelTimeSensitive, InterruptionLevelCritical))
+		return b
+	}
+
+	b.aps()["interruption-level"] = level
+	return b
+}
+
+// SetMutableContent lets the app's notification service extension modify the
+// notification before it is shown.
+func (b *NotificationBuilder) SetMutableContent(value bool) *NotificationBuilder {
+	var intValue int = 0
+	if value {
+		intValue = 1
+	}
+
+	b.aps()["mutable-content"] = intValue
+	return b
+}
+
+// SetRelevanceScore sets how the system ranks the notification in the
+// notification summary, from 0.0 to 1.0.
+func (b *NotificationBuilder) SetRelevanceScore(score float64) *NotificationBuilder {
+	if score < 0 || score > 1 {
+		b.setError(fmt.Errorf("relevance score %g is out of range (must be between 0.0 and 1.0)", score))
+		return b
+	}
+
+	b.aps()["relevance-score"] = score
+	return b
+}
+
+// SetStaleDate sets when the system considers a Live Activity out of date.
+func (b *NotificationBuilder) SetStaleDate(t time.Time) *NotificationBuilder {
+	b.aps()["stale-date"] = t.Unix()
+	return b
+}
+
+// SetTargetContentId sets the identifier of the window brought forward when
+// the notification is opened.
+func (b *NotificationBuilder) SetTargetContentId(id string) *NotificationBuilder {
+	b.aps()["target-content-id"] = id
+	return b
+}
+
+// SetTimestamp sets when the Live Activity content was generated. The system
+// ignores updates older than the one it last applied.
+func (b *NotificationBuilder) SetTimestamp(t time.Time) *NotificationBuilder {
+	b.aps()["timestamp"] = t.Unix()
+	return b
+}
+
+// SetThreadId groups the notification with others that have the same thread
+// identifier.
+func (b *NotificationBuilder) SetThreadId(id string) *NotificationBuilder {
+	b.aps()["thread-id"] = id
+	return b
+}
+
+// SetCollapseId sets the apns-collapse-id header, which lets later
+// notifications with the same ID replace earlier ones on the device.
+func (b *NotificationBuilder) SetCollapseId(id string) *NotificationBuilder {
+	return b.SetHeader(HeaderCollapseId, id)
+}
+
+// SetExpiration sets the apns-expiration header. APNs stops trying to
+// deliver the notification after this time; the zero time tells it to try
+// only once.
+func (b *NotificationBuilder) SetExpiration(t time.Time) *NotificationBuilder {
+	var value int64
+	if !t.IsZero() {
+		value = t.Unix()
+	}
+	return b.SetHeader(HeaderExpiration, strconv.FormatInt(value, 10))
+}
+
+// SetHeader sets a request header, overriding any value the builder would
+// otherwise derive for it.
+func (b *NotificationBuilder) SetHeader(name string, value string) *NotificationBuilder {
+	b.headers[strings.ToLower(name)] = value
+	return b
+}
+
+// SetNotificationId sets the apns-id header, a UUID that APNs returns in its
+// response. APNs generates one if it is not set.
+func (b *NotificationBuilder) SetNotificationId(id string) *NotificationBuilder {
+	return b.SetHeader(HeaderId, id)
+}
+
+func (b *NotificationBuilder) SetPriority(priority int) *NotificationBuilder {
+	return b.SetHeader(HeaderPriority, strconv.Itoa(priority))
+}
+
+// SetPushType sets the apns-push-type header instead of inferring it from the
+// content.
+func (b *NotificationBuilder) SetPushType(pushType string) *NotificationBuilder {
+	b.pushType = pushType
+	return b
+}
+
+// SetCriticalSound marks the sound as a critical alert, which plays even when
+// the device is muted or in Do Not Disturb. The app needs Apple's critical
+// alerts entitlement.
+func (b *NotificationBuilder) SetCriticalSound(critical bool) *NotificationBuilder {
+	var intValue int = 0
+	if critical {
+		intValue = 1
+	}
+
+	b.sound()["critical"] = intValue
+	return b
+}
+
+// SetSoundName sets the name of the sound to play. If no other sound fields
+// are set, the sound is sent in its simple string form.
 func (b *NotificationBuilder) SetSoundName(name string) *NotificationBuilder {
-	b.aps()["sound"] = name
+	if sound, ok := b.aps()["sound"].(map[string]interface{}); ok {
+		sound["name"] = name
+	} else {
+		b.aps()["sound"] = name
+	}
+	return b
+}
+
+// SetSoundVolume sets the volume of a critical alert sound, from 0.0 (silent)
+// to 1.0 (full volume).
+func (b *NotificationBuilder) SetSoundVolume(volume float64) *NotificationBuilder {
+	if volume < 0 || volume > 1 {
+		b.setError(fmt.Errorf("sound volume %g is out of range (must be between 0.0 and 1.0)", volume))
+		return b
+	}
+
+	b.sound()["volume"] = volume
 	return b
 }
 
+// sound returns the sound dictionary, converting a plain sound name into the
+// name in the dictionary. The default sound is used if no name is set.
+func (b *NotificationBuilder) sound() map[string]interface{} {
+	aps := b.aps()
+
+	sound, ok := aps["sound"].(map[string]interface{})
+	if !ok {
+		name, ok := aps["sound"].(string)
+		if !ok {
+			name = "default"
+		}
+		sound = map[string]interface{}{
+			"name": name,
+		}
+		aps["sound"] = sound
+	}
+	return sound
+}
+
+func (b *NotificationBuilder) setError(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
 func hasKey(m map[string]interface{}, name string) bool {
 	_, ok := m[name]
 	return ok
 }
+
+// isFlagSet reports whether an aps flag such as content-available is 1, as
+// set by the builder or decoded from JSON.
+func isFlagSet(value interface{}) bool {
+	switch v := value.(type) {
+	case int:
+		return v == 1
+	case float64:
+		return v == 1
+	case json.Number:
+		return v.String() == "1"
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNotificationBuilderBuildHeaders(t *testing.T) {
+	const appId = "com.example.app"
+
+	tests := []struct {
+		name    string
+		build   func(b *NotificationBuilder)
+		content string
+		want    Headers
+	}{
+		{
+			name:  "alert inferred",
+			build: func(b *NotificationBuilder) { b.SetAlertText("hi") },
+			want:  Headers{HeaderTopic: appId, HeaderPushType: PushTypeAlert},
+		},
+		{
+			name:  "badge inferred as alert",
+			build: func(b *NotificationBuilder) { b.SetBadgeCount(1) },
+			want:  Headers{HeaderTopic: appId, HeaderPushType: PushTypeAlert},
+		},
+		{
+			name:  "background inferred",
+			build: func(b *NotificationBuilder) { b.SetContentAvailable(true) },
+			want:  Headers{HeaderTopic: appId, HeaderPushType: PushTypeBackground, HeaderPriority: "5"},
+		},
+		{
+			name:    "background inferred from JSON",
+			content: `{"aps":{"content-available":1}}`,
+			want:    Headers{HeaderTopic: appId, HeaderPushType: PushTypeBackground, HeaderPriority: "5"},
+		},
+		{
+			name:    "live activity inferred",
+			content: `{"aps":{"event":"end","timestamp":1}}`,
+			want:    Headers{HeaderTopic: appId + ".push-type.liveactivity", HeaderPushType: PushTypeLiveActivity},
+		},
+		{
+			name:    "nothing to infer",
+			content: `{"aps":{"category":"x"}}`,
+			want:    Headers{HeaderTopic: appId},
+		},
+		{
+			name:  "voip suffix and priority",
+			build: func(b *NotificationBuilder) { b.SetPushType(PushTypeVoip) },
+			want:  Headers{HeaderTopic: appId + ".voip", HeaderPushType: PushTypeVoip, HeaderPriority: "10"},
+		},
+		{
+			name:  "complication suffix",
+			build: func(b *NotificationBuilder) { b.SetPushType(PushTypeComplication) },
+			want:  Headers{HeaderTopic: appId + ".complication", HeaderPushType: PushTypeComplication},
+		},
+		{
+			name:  "file provider suffix",
+			build: func(b *NotificationBuilder) { b.SetPushType(PushTypeFileProvider) },
+			want:  Headers{HeaderTopic: appId + ".pushkit.fileprovider", HeaderPushType: PushTypeFileProvider},
+		},
+		{
+			name:  "location suffix",
+			build: func(b *NotificationBuilder) { b.SetPushType(PushTypeLocation) },
+			want:  Headers{HeaderTopic: appId + ".location-query", HeaderPushType: PushTypeLocation, HeaderPriority: "10"},
+		},
+		{
+			name:  "push to talk",
+			build: func(b *NotificationBuilder) { b.SetPushType(PushTypePushToTalk) },
+			want: Headers{
+				HeaderTopic:      appId + ".voip-ptt",
+				HeaderPushType:   PushTypePushToTalk,
+				HeaderPriority:   "10",
+				HeaderExpiration: "0",
+			},
+		},
+		{
+			name:    "mdm has no suffix",
+			content: `{"mdm":"00000000-0000-0000-0000-000000000000"}`,
+			build:   func(b *NotificationBuilder) { b.SetPushType(PushTypeMdm) },
+			want:    Headers{HeaderTopic: appId, HeaderPushType: PushTypeMdm},
+		},
+		{
+			name: "explicit headers override",
+			build: func(b *NotificationBuilder) {
+				b.SetContentAvailable(true)
+				b.SetPriority(10)
+			},
+			want: Headers{HeaderTopic: appId, HeaderPushType: PushTypeBackground, HeaderPriority: "10"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := NewNotificationBuilder(appId)
+			if test.content != "" {
+				var content map[string]interface{}
+				if err := json.Unmarshal([]byte(test.content), &content); err != nil {
+					t.Fatal(err)
+				}
+				b.Merge(content)
+			}
+			if test.build != nil {
+				test.build(b)
+			}
+
+			headers, err := b.BuildHeaders()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(headers, test.want) {
+				t.Errorf("got %v, want %v", headers, test.want)
+			}
+		})
+	}
+}
+
+func TestNotificationBuilderKeepsContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		pushType string
+		content  string
+	}{
+		{name: "no aps", pushType: PushTypeMdm, content: `{"mdm":"00000000-0000-0000-0000-000000000000"}`},
+		{name: "no aps or push type", content: `{"mdm":"00000000-0000-0000-0000-000000000000"}`},
+		{name: "aps not an object", content: `{"aps":"hello"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var content map[string]interface{}
+			if err := json.Unmarshal([]byte(test.content), &content); err != nil {
+				t.Fatal(err)
+			}
+
+			b := NewNotificationBuilder("com.example.app").Merge(content)
+			if test.pushType != "" {
+				b.SetPushType(test.pushType)
+			}
+
+			_, data, err := b.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.content {
+				t.Errorf("got %s, want %s", data, test.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import "strings"
+
+// Values for the apns-push-type header.
+const (
+	PushTypeAlert        = "alert"
+	PushTypeBackground   = "background"
+	PushTypeComplication = "complication"
+	PushTypeFileProvider = "fileprovider"
+	PushTypeLiveActivity = "liveactivity"
+	PushTypeLocation     = "location"
+	PushTypeMdm          = "mdm"
+	PushTypePushToTalk   = "pushtotalk"
+	PushTypeVoip         = "voip"
+)
+
+// topicSuffixes maps push types to the suffix APNs requires on the app's
+// bundle ID in the apns-topic header.
+var topicSuffixes = map[string]string{
+	PushTypeComplication: ".complication",
+	PushTypeFileProvider: ".pushkit.fileprovider",
+	PushTypeLiveActivity: ".push-type.liveactivity",
+	PushTypeLocation:     ".location-query",
+	PushTypePushToTalk:   ".voip-ptt",
+	PushTypeVoip:         ".voip",
+}
+
+// pushTypePriorities maps push types to the apns-priority APNs requires for
+// them.
+var pushTypePriorities = map[string]string{
+	PushTypeBackground: "5",
+	PushTypeLocation:   "10",
+	PushTypePushToTalk: "10",
+	PushTypeVoip:       "10",
+}
+
+// TopicForPushType returns the apns-topic for sending the given push type to
+// the app, appending the suffix the push type requires if appId does not
+// already end with it.
+func TopicForPushType(appId string, pushType string) string {
+	suffix := topicSuffixes[pushType]
+	if suffix == "" || strings.HasSuffix(appId, suffix) {
+		return appId
+	}
+	return appId + suffix
+}
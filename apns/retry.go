@@ -0,0 +1,134 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// RetryPolicy controls how the client retries notifications that fail with a
+// transient error: a 429, 500 or 503 response, a connection that could not be
+// opened, or a stream APNs refused before processing it. Permanent failures
+// such as BadDeviceToken are never retried, and neither is a connection lost
+// after the request was written, since APNs may already have delivered it.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a notification is sent,
+	// including the first attempt. Values less than 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the upper bound of the delay before the first retry.
+	// The bound doubles on each subsequent retry, up to MaxBackoff, and the
+	// actual delay is chosen at random below it.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    1,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+}
+
+// backoff returns the delay before the retry that follows the given attempt,
+// using exponential backoff with full jitter.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	limit := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || limit < p.MaxBackoff); i++ {
+		limit *= 2
+	}
+	if p.MaxBackoff > 0 && limit > p.MaxBackoff {
+		limit = p.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(limit))) + 1
+}
+
+func isRetryable(ctx context.Context, result *SendResult, err error) bool {
+	if err != nil {
+		// The request never completed. Unless the caller gave up, it can be
+		// sent again if it provably never reached APNs.
+		return ctx.Err() == nil && isUnsent(err)
+	}
+
+	if apnsErr := result.APNsError(); apnsErr != nil {
+		return apnsErr.IsRetryable()
+	}
+	return false
+}
+
+// isUnsent reports whether err shows that the request never reached APNs, so
+// sending it again cannot deliver the notification twice. That is the case
+// when the connection could not be opened or APNs refused the stream before
+// processing it. A connection that is lost after the request was written is
+// ambiguous and is not retried. (The http2 transport already resends requests
+// that a GOAWAY frame shows were not processed.)
+func isUnsent(err error) bool {
+	var streamErr http2.StreamError
+	if errors.As(err, &streamErr) {
+		return streamErr.Code == http2.ErrCodeRefusedStream
+	}
+
+	var dialErr *dialError
+	if !errors.As(err, &dialErr) {
+		return false
+	}
+
+	// The server's certificate or our own was rejected, which will not
+	// change on the next attempt.
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &unknownAuthorityErr) || errors.As(err, &certificateInvalidErr) || errors.As(err, &hostnameErr) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		return false
+	}
+
+	return true
+}
+
+// dialError is a failure to open a connection to APNs, before any request was
+// written to it.
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string {
+	return e.err.Error()
+}
+
+func (e *dialError) Unwrap() error {
+	return e.err
+}
+
+// dialTLS opens connections for the http2 transport, marking its errors with
+// dialError.
+func dialTLS(network string, addr string, cfg *tls.Config) (net.Conn, error) {
+	conn, err := tls.Dial(network, addr, cfg)
+	if err != nil {
+		return nil, &dialError{err}
+	}
+
+	if protocol := conn.ConnectionState().NegotiatedProtocol; protocol != http2.NextProtoTLS {
+		conn.Close()
+		return nil, &dialError{fmt.Errorf("unexpected ALPN protocol %q; want %q", protocol, http2.NextProtoTLS)}
+	}
+
+	return conn, nil
+}
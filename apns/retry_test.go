@@ -0,0 +1,123 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func TestIsRetryable(t *testing.T) {
+	urlError := func(err error) error {
+		return &url.Error{Op: "Post", URL: "https://api.push.apple.com/3/device/token", Err: err}
+	}
+	response := func(statusCode int, reason string) *SendResult {
+		result := &SendResult{StatusCode: statusCode}
+		if reason != "" {
+			result.content = []byte(`{"reason":"` + reason + `"}`)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		result *SendResult
+		err    error
+		want   bool
+	}{
+		{name: "accepted", result: response(200, "")},
+		{name: "too many requests", result: response(429, "TooManyRequests"), want: true},
+		{name: "internal server error", result: response(500, "InternalServerError"), want: true},
+		{name: "service unavailable", result: response(503, "ServiceUnavailable"), want: true},
+		{name: "bad device token", result: response(400, "BadDeviceToken")},
+		{name: "unregistered", result: response(410, "Unregistered")},
+		{name: "unknown status", result: response(503, ""), want: true},
+		{
+			name: "connection refused",
+			err:  urlError(&dialError{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}),
+			want: true,
+		},
+		{
+			name: "connection reset during handshake",
+			err:  urlError(&dialError{&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}),
+			want: true,
+		},
+		{
+			name: "refused stream",
+			err:  urlError(http2.StreamError{StreamID: 1, Code: http2.ErrCodeRefusedStream}),
+			want: true,
+		},
+		{name: "stream reset", err: urlError(http2.StreamError{StreamID: 1, Code: http2.ErrCodeInternal})},
+		{name: "GOAWAY after request was sent", err: urlError(http2.GoAwayError{LastStreamID: 1})},
+		{name: "unexpected EOF after request was sent", err: urlError(io.ErrUnexpectedEOF)},
+		{name: "connection reset after request was sent", err: urlError(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET})},
+		{name: "untrusted server certificate", err: urlError(&dialError{x509.UnknownAuthorityError{}})},
+		{name: "server hostname mismatch", err: urlError(&dialError{x509.HostnameError{Host: "localhost"}})},
+		{
+			name: "client certificate rejected",
+			err:  urlError(&dialError{&net.OpError{Op: "remote error", Err: errors.New("tls: bad certificate")}}),
+		},
+		{name: "token signing failure", err: errors.New("failed to sign provider token")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isRetryable(context.Background(), test.result, test.err); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsRetryableCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := &dialError{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+	if isRetryable(ctx, nil, err) {
+		t.Error("got retryable after the context was canceled")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+
+	tests := []struct {
+		attempt int
+		limit   time.Duration
+	}{
+		{attempt: 1, limit: 100 * time.Millisecond},
+		{attempt: 2, limit: 200 * time.Millisecond},
+		{attempt: 3, limit: 400 * time.Millisecond},
+		{attempt: 4, limit: 800 * time.Millisecond},
+		{attempt: 5, limit: time.Second},
+		{attempt: 50, limit: time.Second},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			if backoff := policy.backoff(test.attempt); backoff <= 0 || backoff > test.limit {
+				t.Fatalf("attempt %d: got backoff %s, want in (0, %s]", test.attempt, backoff, test.limit)
+			}
+		}
+	}
+
+	if backoff := (RetryPolicy{}).backoff(1); backoff != 0 {
+		t.Errorf("got backoff %s with no initial backoff, want 0", backoff)
+	}
+}
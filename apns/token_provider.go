@@ -0,0 +1,122 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"crypto/ecdsa"
+	"sync"
+	"time"
+)
+
+const (
+	// APNs rejects provider tokens older than an hour and throttles providers
+	// that replace their token more often than every 20 minutes.
+	MinTokenRefreshInterval     = 20 * time.Minute
+	MaxTokenRefreshInterval     = 60 * time.Minute
+	DefaultTokenRefreshInterval = 50 * time.Minute
+)
+
+// TokenProvider supplies the provider token (JWT) sent in the authorization
+// header of each request.
+type TokenProvider interface {
+	Token() (string, error)
+
+	// Invalidate discards token after APNs reports it as expired. It returns
+	// true if the next call to Token will return a different token.
+	Invalidate(token string) bool
+}
+
+// KeyTokenProvider signs provider tokens with an APNs-enabled private key and
+// caches each token until its refresh interval elapses.
+type KeyTokenProvider struct {
+	Key    *ecdsa.PrivateKey
+	KeyId  string
+	TeamId string
+
+	// ExpiresAfter sets the token's expiration claim, relative to the time it
+	// was issued.
+	ExpiresAfter time.Duration
+
+	// RefreshInterval is how long a token is reused before a new one is
+	// signed. It is clamped to the range APNs accepts.
+	RefreshInterval time.Duration
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func NewKeyTokenProvider(key *ecdsa.PrivateKey, keyId string, teamId string) *KeyTokenProvider {
+	return &KeyTokenProvider{
+		Key:             key,
+		KeyId:           keyId,
+		TeamId:          teamId,
+		ExpiresAfter:    MaxTokenRefreshInterval,
+		RefreshInterval: DefaultTokenRefreshInterval,
+	}
+}
+
+func NewKeyTokenProviderFromFile(keyFile string, keyId string, teamId string) (*KeyTokenProvider, error) {
+	key, err := LoadKeyFromFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyTokenProvider(key, keyId, teamId), nil
+}
+
+func (p *KeyTokenProvider) Token() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	if p.token != "" && now.Sub(p.issuedAt) < p.refreshInterval() {
+		return p.token, nil
+	}
+
+	token, err := GenerateJWTFromKey(p.Key, p.KeyId, p.TeamId, now, p.ExpiresAfter)
+	if err != nil {
+		return "", err
+	}
+
+	p.token = token
+	p.issuedAt = now
+
+	return p.token, nil
+}
+
+func (p *KeyTokenProvider) Invalidate(token string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token == token {
+		p.token = ""
+	}
+	return true
+}
+
+func (p *KeyTokenProvider) refreshInterval() time.Duration {
+	switch {
+	case p.RefreshInterval < MinTokenRefreshInterval:
+		return MinTokenRefreshInterval
+	case p.RefreshInterval >= MaxTokenRefreshInterval:
+		// Leave a margin so the token is replaced before APNs rejects it.
+		return MaxTokenRefreshInterval - time.Minute
+	}
+	return p.RefreshInterval
+}
+
+// staticTokenProvider always returns the same token. It backs
+// Client.ConfigureTokenAuth.
+type staticTokenProvider string
+
+func (p staticTokenProvider) Token() (string, error) {
+	return string(p), nil
+}
+
+func (p staticTokenProvider) Invalidate(token string) bool {
+	return false
+}
@@ -0,0 +1,250 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Maximum payload sizes accepted by APNs, in bytes.
+const (
+	MaxPayloadSize     = 4096
+	MaxVoipPayloadSize = 5120
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// ValidationIssue is a problem Validate found with a notification. Errors
+// are problems APNs would reject the notification for; warnings are likely
+// mistakes that APNs accepts.
+type ValidationIssue struct {
+	Severity Severity `json:"severity" yaml:"severity"`
+	Message  string   `json:"message" yaml:"message"`
+}
+
+func (issue ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s", issue.Severity, issue.Message)
+}
+
+type ValidationIssues []ValidationIssue
+
+// HasErrors reports whether any of the issues is an error.
+func (issues ValidationIssues) HasErrors() bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns only the issues that are errors.
+func (issues ValidationIssues) Errors() ValidationIssues {
+	var errs ValidationIssues
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	return errs
+}
+
+// ValidationError is returned by Client.SendContext, when validation is
+// enabled with ConfigureValidation, for a notification APNs would reject.
+type ValidationError struct {
+	Issues ValidationIssues
+}
+
+func (e *ValidationError) Error() string {
+	messages := []string{}
+	for _, issue := range e.Issues.Errors() {
+		messages = append(messages, issue.Message)
+	}
+	return "apns: invalid notification: " + strings.Join(messages, "; ")
+}
+
+// knownApsKeys are the keys Apple defines for the aps dictionary.
+var knownApsKeys = map[string]bool{
+	"alert":              true,
+	"attributes":         true,
+	"attributes-type":    true,
+	"badge":              true,
+	"category":           true,
+	"content-available":  true,
+	"content-state":      true,
+	"dismissal-date":     true,
+	"event":              true,
+	"filter-criteria":    true,
+	"interruption-level": true,
+	"mutable-content":    true,
+	"relevance-score":    true,
+	"sound":              true,
+	"stale-date":         true,
+	"target-content-id":  true,
+	"thread-id":          true,
+	"timestamp":          true,
+	"url-args":           true,
+}
+
+// Validate checks a notification's headers and payload against the rules
+// APNs enforces, without sending it. Client does not call it unless
+// validation is enabled with ConfigureValidation.
+func Validate(headers Headers, payload []byte) ValidationIssues {
+	v := &validator{}
+
+	pushType := headers[HeaderPushType]
+	priority := headers[HeaderPriority]
+	topic := headers[HeaderTopic]
+
+	v.validateSize(pushType, payload)
+	aps := v.validatePayload(pushType, payload)
+	v.validatePushType(pushType, priority, topic, headers[HeaderExpiration], aps)
+
+	return v.issues
+}
+
+type validator struct {
+	issues ValidationIssues
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.issues = append(v.issues, ValidationIssue{SeverityError, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(format string, args ...interface{}) {
+	v.issues = append(v.issues, ValidationIssue{SeverityWarning, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validateSize(pushType string, payload []byte) {
+	maxSize := MaxPayloadSize
+	if pushType == PushTypeVoip {
+		maxSize = MaxVoipPayloadSize
+	}
+
+	if len(payload) == 0 {
+		v.errorf("payload is empty")
+	} else if len(payload) > maxSize {
+		v.errorf("payload is %d bytes, more than the %d bytes allowed", len(payload), maxSize)
+	}
+}
+
+// validatePayload checks the payload structure and returns the aps
+// dictionary, or nil if there is none.
+func (v *validator) validatePayload(pushType string, payload []byte) map[string]interface{} {
+	if len(payload) == 0 {
+		return nil
+	}
+
+	var content map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&content); err != nil {
+		v.errorf("payload is not a JSON object: %s", err)
+		return nil
+	}
+
+	value, ok := content["aps"]
+	if !ok {
+		switch pushType {
+		case PushTypeAlert, PushTypeBackground, PushTypeLiveActivity:
+			v.errorf("payload has no aps dictionary, which %s notifications require", pushType)
+		}
+		return nil
+	}
+
+	aps, ok := value.(map[string]interface{})
+	if !ok {
+		v.errorf("aps must be an object")
+		return nil
+	}
+
+	unknown := []string{}
+	for key := range aps {
+		if !knownApsKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		v.warnf("unknown aps key %q (custom data belongs outside aps)", key)
+	}
+
+	return aps
+}
+
+func (v *validator) validatePushType(pushType string, priority string, topic string, expiration string, aps map[string]interface{}) {
+	// APNs sends notifications without an apns-priority header immediately.
+	priorityText := priority
+	if priority == "" {
+		priority = "10"
+		priorityText = "the default 10"
+	}
+
+	if pushType == "" {
+		v.warnf("no %s header (required for watchOS 6, iOS 13 and later)", HeaderPushType)
+	}
+
+	if topic == "" {
+		v.warnf("no %s header (required with token-based authentication)", HeaderTopic)
+	}
+
+	contentAvailable := aps != nil && isFlagSet(aps["content-available"])
+	hasAlert := aps != nil && (hasKey(aps, "alert") || hasKey(aps, "badge") || hasKey(aps, "sound"))
+
+	if pushType != PushTypeBackground && contentAvailable && !hasAlert && priority != "5" {
+		v.errorf("content-available notifications must be sent with priority 5, not %s", priorityText)
+	}
+
+	switch pushType {
+	case PushTypeAlert:
+		if aps != nil && !hasAlert {
+			v.warnf("alert notification has no alert, badge or sound")
+		}
+	case PushTypeBackground:
+		if aps != nil && !contentAvailable {
+			v.errorf("background notification must set content-available to 1")
+		}
+		if hasAlert {
+			v.warnf("background notification has an alert, badge or sound; use the alert push type")
+		}
+	case PushTypePushToTalk:
+		if expiration != "" && expiration != "0" {
+			v.warnf("pushtotalk notifications should be sent with %s 0", HeaderExpiration)
+		}
+	}
+
+	if required, ok := pushTypePriorities[pushType]; ok && priority != required {
+		if pushType == PushTypeBackground {
+			v.errorf("%s notifications must be sent with priority %s, not %s", pushType, required, priorityText)
+		} else {
+			v.warnf("%s notifications should be sent with priority %s, not %s", pushType, required, priorityText)
+		}
+	}
+
+	if topic == "" || pushType == "" {
+		return
+	}
+
+	if suffix := topicSuffixes[pushType]; suffix != "" && !strings.HasSuffix(topic, suffix) {
+		v.errorf("topic %q must end with %q for %s notifications", topic, suffix, pushType)
+		return
+	}
+
+	for otherType, suffix := range topicSuffixes {
+		if otherType != pushType && strings.HasSuffix(topic, suffix) && !strings.HasSuffix(topicSuffixes[pushType], suffix) {
+			v.errorf("topic %q is for %s notifications, not %s", topic, otherType, pushType)
+			return
+		}
+	}
+}
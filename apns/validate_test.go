@@ -0,0 +1,196 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apns
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const topic = "com.example.app"
+
+	alertHeaders := Headers{HeaderTopic: topic, HeaderPushType: PushTypeAlert}
+	backgroundHeaders := Headers{HeaderTopic: topic, HeaderPushType: PushTypeBackground, HeaderPriority: "5"}
+
+	tests := []struct {
+		name    string
+		headers Headers
+		payload string
+		want    ValidationIssues
+	}{
+		{
+			name:    "valid alert",
+			headers: alertHeaders,
+			payload: `{"aps":{"alert":"hi"}}`,
+		},
+		{
+			name:    "valid background",
+			headers: backgroundHeaders,
+			payload: `{"aps":{"content-available":1}}`,
+		},
+		{
+			name:    "empty payload",
+			headers: alertHeaders,
+			want:    ValidationIssues{{SeverityError, "payload is empty"}},
+		},
+		{
+			name:    "payload too large",
+			headers: alertHeaders,
+			payload: `{"aps":{"alert":"` + strings.Repeat("x", MaxPayloadSize) + `"}}`,
+			want:    ValidationIssues{{SeverityError, "payload is 4116 bytes, more than the 4096 bytes allowed"}},
+		},
+		{
+			name:    "voip payload over alert limit",
+			headers: Headers{HeaderTopic: topic + ".voip", HeaderPushType: PushTypeVoip, HeaderPriority: "10"},
+			payload: `{"data":"` + strings.Repeat("x", MaxPayloadSize) + `"}`,
+		},
+		{
+			name:    "not JSON",
+			headers: alertHeaders,
+			payload: `hello`,
+			want:    ValidationIssues{{SeverityError, "payload is not a JSON object: invalid character 'h' looking for beginning of value"}},
+		},
+		{
+			name:    "aps not an object",
+			headers: alertHeaders,
+			payload: `{"aps":"hi"}`,
+			want:    ValidationIssues{{SeverityError, "aps must be an object"}},
+		},
+		{
+			name:    "missing aps",
+			headers: alertHeaders,
+			payload: `{"data":1}`,
+			want:    ValidationIssues{{SeverityError, "payload has no aps dictionary, which alert notifications require"}},
+		},
+		{
+			name:    "mdm without aps",
+			headers: Headers{HeaderTopic: topic, HeaderPushType: PushTypeMdm},
+			payload: `{"mdm":"magic"}`,
+		},
+		{
+			name:    "unknown aps key",
+			headers: alertHeaders,
+			payload: `{"aps":{"alert":"hi","custom":1}}`,
+			want:    ValidationIssues{{SeverityWarning, `unknown aps key "custom" (custom data belongs outside aps)`}},
+		},
+		{
+			name:    "missing headers",
+			headers: Headers{},
+			payload: `{"aps":{"alert":"hi"}}`,
+			want: ValidationIssues{
+				{SeverityWarning, "no apns-push-type header (required for watchOS 6, iOS 13 and later)"},
+				{SeverityWarning, "no apns-topic header (required with token-based authentication)"},
+			},
+		},
+		{
+			name:    "alert without alert",
+			headers: alertHeaders,
+			payload: `{"aps":{"category":"x"}}`,
+			want:    ValidationIssues{{SeverityWarning, "alert notification has no alert, badge or sound"}},
+		},
+		{
+			name:    "background without content-available",
+			headers: backgroundHeaders,
+			payload: `{"aps":{}}`,
+			want:    ValidationIssues{{SeverityError, "background notification must set content-available to 1"}},
+		},
+		{
+			name:    "background with alert",
+			headers: backgroundHeaders,
+			payload: `{"aps":{"content-available":1,"alert":"hi"}}`,
+			want:    ValidationIssues{{SeverityWarning, "background notification has an alert, badge or sound; use the alert push type"}},
+		},
+		{
+			name:    "background with priority 10",
+			headers: Headers{HeaderTopic: topic, HeaderPushType: PushTypeBackground, HeaderPriority: "10"},
+			payload: `{"aps":{"content-available":1}}`,
+			want:    ValidationIssues{{SeverityError, "background notifications must be sent with priority 5, not 10"}},
+		},
+		{
+			name:    "background with default priority",
+			headers: Headers{HeaderTopic: topic, HeaderPushType: PushTypeBackground},
+			payload: `{"aps":{"content-available":1}}`,
+			want:    ValidationIssues{{SeverityError, "background notifications must be sent with priority 5, not the default 10"}},
+		},
+		{
+			name:    "content-available without push type or priority",
+			headers: Headers{HeaderTopic: topic},
+			payload: `{"aps":{"content-available":1}}`,
+			want: ValidationIssues{
+				{SeverityWarning, "no apns-push-type header (required for watchOS 6, iOS 13 and later)"},
+				{SeverityError, "content-available notifications must be sent with priority 5, not the default 10"},
+			},
+		},
+		{
+			name:    "voip with priority 5",
+			headers: Headers{HeaderTopic: topic + ".voip", HeaderPushType: PushTypeVoip, HeaderPriority: "5"},
+			payload: `{"aps":{}}`,
+			want:    ValidationIssues{{SeverityWarning, "voip notifications should be sent with priority 10, not 5"}},
+		},
+		{
+			name:    "pushtotalk with expiration",
+			headers: Headers{HeaderTopic: topic + ".voip-ptt", HeaderPushType: PushTypePushToTalk, HeaderExpiration: "60"},
+			payload: `{"aps":{}}`,
+			want:    ValidationIssues{{SeverityWarning, "pushtotalk notifications should be sent with apns-expiration 0"}},
+		},
+		{
+			name:    "missing topic suffix",
+			headers: Headers{HeaderTopic: topic, HeaderPushType: PushTypeVoip},
+			payload: `{"aps":{}}`,
+			want:    ValidationIssues{{SeverityError, `topic "com.example.app" must end with ".voip" for voip notifications`}},
+		},
+		{
+			name:    "topic for another push type",
+			headers: Headers{HeaderTopic: topic + ".voip", HeaderPushType: PushTypeAlert},
+			payload: `{"aps":{"alert":"hi"}}`,
+			want:    ValidationIssues{{SeverityError, `topic "com.example.app.voip" is for voip notifications, not alert`}},
+		},
+		{
+			name:    "pushtotalk topic is not voip",
+			headers: Headers{HeaderTopic: topic + ".voip-ptt", HeaderPushType: PushTypePushToTalk, HeaderExpiration: "0"},
+			payload: `{"aps":{}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issues := Validate(test.headers, []byte(test.payload))
+			if !reflect.DeepEqual(issues, test.want) {
+				t.Errorf("got %v, want %v", issues, test.want)
+			}
+		})
+	}
+}
+
+func TestClientValidation(t *testing.T) {
+	client, server := newTestClient(t)
+	defer server.Close()
+
+	headers := Headers{HeaderTopic: "com.example.app", HeaderPushType: PushTypeBackground}
+	content := []byte(`{"aps":{"content-available":1}}`)
+
+	client.ConfigureValidation(true)
+
+	_, err := client.Send(testDeviceToken, headers, content)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want *ValidationError", err)
+	}
+	if len(server.Received()) != 0 {
+		t.Errorf("invalid notification was sent")
+	}
+
+	client.ConfigureValidation(false)
+
+	if _, err := client.Send(testDeviceToken, headers, content); err != nil {
+		t.Fatalf("got error %v with validation disabled", err)
+	}
+	if len(server.Received()) != 1 {
+		t.Errorf("notification was not sent with validation disabled")
+	}
+}
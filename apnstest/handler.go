@@ -0,0 +1,332 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apnstest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pascaldekloe/jwt"
+)
+
+const (
+	devicePathPrefix = "/3/device/"
+
+	maxPayloadSize     = 4096
+	maxVoipPayloadSize = 5120
+	maxCollapseIdSize  = 64
+
+	// Provider tokens older than this are rejected as expired.
+	maxProviderTokenAge = time.Hour
+)
+
+var (
+	deviceTokenPattern = regexp.MustCompile(`^([0-9a-fA-F]{2}){32,100}$`)
+	apnsIdPattern      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	pushTypes = map[string]bool{
+		"alert":        true,
+		"background":   true,
+		"complication": true,
+		"controls":     true,
+		"fileprovider": true,
+		"liveactivity": true,
+		"location":     true,
+		"mdm":          true,
+		"pushtotalk":   true,
+		"voip":         true,
+		"widgets":      true,
+	}
+)
+
+// Notification is a request received by the server, along with the response
+// it was given.
+type Notification struct {
+	ReceivedAt  time.Time
+	DeviceToken string
+	Headers     http.Header
+	Payload     []byte
+
+	// StatusCode is zero if the connection was reset instead of answered.
+	StatusCode int
+	Reason     string
+	ApnsId     string
+}
+
+type errorResponse struct {
+	Reason    string `json:"reason"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	payload, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxVoipPayloadSize+1))
+
+	n := &Notification{
+		ReceivedAt:  time.Now(),
+		DeviceToken: strings.TrimPrefix(r.URL.Path, devicePathPrefix),
+		Headers:     r.Header,
+		Payload:     payload,
+		ApnsId:      r.Header.Get("apns-id"),
+	}
+
+	if n.ApnsId == "" || !apnsIdPattern.MatchString(n.ApnsId) {
+		n.ApnsId = newUUID()
+	}
+
+	rule := s.matchRule(r, n.DeviceToken)
+	if rule == nil {
+		n.StatusCode, n.Reason = s.validate(r, n)
+
+		s.record(n)
+		s.respond(w, n, time.Time{})
+		return
+	}
+
+	if rule.Delay > 0 {
+		select {
+		case <-time.After(time.Duration(rule.Delay)):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	state, _ := r.Context().Value(connStateKey{}).(*connState)
+
+	if rule.Action == ActionReset {
+		s.record(n)
+		if state != nil {
+			_ = state.conn.Close()
+		}
+		return
+	}
+
+	if rule.Status != 0 {
+		n.StatusCode, n.Reason = rule.Status, rule.Reason
+	} else {
+		n.StatusCode, n.Reason = s.validate(r, n)
+	}
+
+	timestamp := time.Time{}
+	if n.StatusCode == http.StatusGone {
+		timestamp = rule.Timestamp
+		if timestamp.IsZero() {
+			timestamp = n.ReceivedAt
+		}
+	}
+
+	s.record(n)
+	s.respond(w, n, timestamp)
+
+	if rule.Action == ActionGoAway && state != nil {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		go state.goAway()
+	}
+}
+
+// validate checks a request the way APNs does, returning the status code and
+// error reason for the response.
+func (s *Server) validate(r *http.Request, n *Notification) (int, string) {
+	if r.Method != http.MethodPost {
+		return http.StatusMethodNotAllowed, "MethodNotAllowed"
+	}
+
+	if !strings.HasPrefix(r.URL.Path, devicePathPrefix) {
+		return http.StatusNotFound, "BadPath"
+	}
+
+	if n.DeviceToken == "" {
+		return http.StatusBadRequest, "MissingDeviceToken"
+	}
+
+	if !deviceTokenPattern.MatchString(n.DeviceToken) {
+		return http.StatusBadRequest, "BadDeviceToken"
+	}
+
+	for name, values := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), "apns-") && len(values) > 1 {
+			return http.StatusBadRequest, "DuplicateHeaders"
+		}
+	}
+
+	if status, reason := s.authenticate(r); status != http.StatusOK {
+		return status, reason
+	}
+
+	topic := r.Header.Get("apns-topic")
+	if topic == "" {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return http.StatusBadRequest, "MissingTopic"
+		}
+	} else if strings.ContainsAny(topic, " \t") {
+		return http.StatusBadRequest, "BadTopic"
+	} else if !s.topicAllowed(topic) {
+		return http.StatusBadRequest, "TopicDisallowed"
+	}
+
+	pushType := r.Header.Get("apns-push-type")
+	if pushType != "" && !pushTypes[pushType] {
+		return http.StatusBadRequest, "InvalidPushType"
+	}
+
+	if id := r.Header.Get("apns-id"); id != "" && !apnsIdPattern.MatchString(id) {
+		return http.StatusBadRequest, "BadMessageId"
+	}
+
+	if priority := r.Header.Get("apns-priority"); priority != "" &&
+		priority != "1" && priority != "5" && priority != "10" {
+		return http.StatusBadRequest, "BadPriority"
+	}
+
+	if expiration := r.Header.Get("apns-expiration"); expiration != "" {
+		if _, err := strconv.ParseInt(expiration, 10, 64); err != nil {
+			return http.StatusBadRequest, "BadExpirationDate"
+		}
+	}
+
+	if len(r.Header.Get("apns-collapse-id")) > maxCollapseIdSize {
+		return http.StatusBadRequest, "BadCollapseId"
+	}
+
+	if len(n.Payload) == 0 {
+		return http.StatusBadRequest, "PayloadEmpty"
+	}
+
+	maxSize := maxPayloadSize
+	if pushType == "voip" {
+		maxSize = maxVoipPayloadSize
+	}
+	if len(n.Payload) > maxSize {
+		return http.StatusRequestEntityTooLarge, "PayloadTooLarge"
+	}
+
+	return http.StatusOK, ""
+}
+
+// authenticate checks the request's client certificate or provider token.
+func (s *Server) authenticate(r *http.Request) (int, string) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		cert := r.TLS.PeerCertificates[0]
+		if time.Now().After(cert.NotAfter) || time.Now().Before(cert.NotBefore) {
+			return http.StatusForbidden, "BadCertificate"
+		}
+		return http.StatusOK, ""
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "bearer ") {
+		return http.StatusForbidden, "MissingProviderToken"
+	}
+	token := []byte(strings.TrimSpace(authorization[7:]))
+
+	claims, err := jwt.ParseWithoutCheck(token)
+	if err != nil || claims.KeyID == "" || claims.Issuer == "" || claims.Issued == nil {
+		return http.StatusForbidden, "InvalidProviderToken"
+	}
+
+	if len(s.TokenKeys) > 0 {
+		key, ok := s.TokenKeys[claims.KeyID]
+		if !ok {
+			return http.StatusForbidden, "InvalidProviderToken"
+		}
+		if _, err := jwt.ECDSACheck(token, key); err != nil {
+			return http.StatusForbidden, "InvalidProviderToken"
+		}
+	}
+
+	if time.Since(claims.Issued.Time()) > maxProviderTokenAge {
+		return http.StatusForbidden, "ExpiredProviderToken"
+	}
+
+	return http.StatusOK, ""
+}
+
+func (s *Server) topicAllowed(topic string) bool {
+	if len(s.Topics) == 0 {
+		return true
+	}
+
+	for _, allowed := range s.Topics {
+		if topic == allowed || strings.HasPrefix(topic, allowed+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) record(n *Notification) {
+	s.mu.Lock()
+	s.received = append(s.received, *n)
+	s.mu.Unlock()
+
+	status := strconv.Itoa(n.StatusCode)
+	if n.StatusCode == 0 {
+		status = "connection reset"
+	} else if n.Reason != "" {
+		status += " " + n.Reason
+	}
+
+	s.logf("%s device=%s topic=%s push-type=%s priority=%s apns-id=%s -> %s\n",
+		n.ReceivedAt.Format(time.RFC3339),
+		n.DeviceToken,
+		n.Headers.Get("apns-topic"),
+		n.Headers.Get("apns-push-type"),
+		n.Headers.Get("apns-priority"),
+		n.ApnsId,
+		status,
+	)
+	s.logf("  %s\n", n.Payload)
+}
+
+func (s *Server) respond(w http.ResponseWriter, n *Notification, timestamp time.Time) {
+	w.Header().Set("apns-id", n.ApnsId)
+	w.Header().Set("apns-unique-id", newUUID())
+
+	if n.StatusCode == http.StatusOK {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	body := errorResponse{Reason: n.Reason}
+	if !timestamp.IsZero() {
+		body.Timestamp = timestamp.UnixNano() / int64(time.Millisecond)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(n.StatusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log == nil {
+		return
+	}
+
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+
+	_, _ = io.WriteString(s.Log, fmt.Sprintf(format, args...))
+}
+
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	h := strings.ToUpper(hex.EncodeToString(b[:]))
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package apnstest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Action is what the server does with a notification matched by a Rule,
+// beyond the response it sends.
+type Action string
+
+const (
+	// ActionRespond sends the rule's response. It is the default.
+	ActionRespond Action = "respond"
+
+	// ActionGoAway sends the response and then a GOAWAY frame, gracefully
+	// closing the connection the way APNs does when it shuts down.
+	ActionGoAway Action = "goaway"
+
+	// ActionReset closes the connection without sending a response.
+	ActionReset Action = "reset"
+)
+
+// Rule forces a response for the notifications it matches, overriding the
+// server's normal validation. Rules are checked in order; the first match
+// applies.
+type Rule struct {
+	// DeviceToken and Topic restrict the rule to matching notifications.
+	// Empty values match any notification.
+	DeviceToken string `json:"device-token,omitempty"`
+	Topic       string `json:"topic,omitempty"`
+
+	// Percentage applies the rule to that share of matching notifications,
+	// chosen at random. Zero applies it to all of them.
+	Percentage float64 `json:"percentage,omitempty"`
+
+	// Times limits how many notifications the rule applies to. Zero means
+	// no limit.
+	Times int `json:"times,omitempty"`
+
+	Action Action `json:"action,omitempty"`
+
+	// Status and Reason set the response. If Status is zero the notification
+	// is validated and answered normally.
+	Status int    `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+
+	// Timestamp is reported with 410 responses. It defaults to the time the
+	// notification was received.
+	Timestamp time.Time `json:"timestamp,omitempty"`
+
+	// Delay is how long to wait before responding.
+	Delay Duration `json:"delay,omitempty"`
+}
+
+// Duration is a time.Duration that is read from JSON as a string such as
+// "1.5s", or as a number of nanoseconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case float64:
+		*d = Duration(value)
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return errors.New("invalid duration: " + string(data))
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadRulesFromFile reads a JSON array of rules.
+func LoadRulesFromFile(filePath string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		switch rule.Action {
+		case "", ActionRespond, ActionGoAway, ActionReset:
+		default:
+			return nil, errors.New("unknown rule action: " + string(rule.Action))
+		}
+	}
+
+	return rules, nil
+}
+
+// matchRule returns the first rule that applies to the request, or nil.
+func (s *Server) matchRule(r *http.Request, deviceToken string) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ruleHits == nil {
+		s.ruleHits = make(map[int]int)
+	}
+
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+
+		if rule.DeviceToken != "" && rule.DeviceToken != deviceToken {
+			continue
+		}
+		if rule.Topic != "" && rule.Topic != r.Header.Get("apns-topic") {
+			continue
+		}
+		if rule.Times > 0 && s.ruleHits[i] >= rule.Times {
+			continue
+		}
+		if rule.Percentage > 0 && s.random().Float64()*100 >= rule.Percentage {
+			continue
+		}
+
+		s.ruleHits[i]++
+		return rule
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package apnstest provides a local stand-in for the APNs provider API, for
+// exercising APNs clients without reaching Apple's servers.
+package apnstest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const DefaultAddr = "localhost:2197"
+
+// Server serves the APNs /3/device/{token} API over HTTP/2 and TLS.
+//
+// A Server is configured by setting its fields after NewServer and before
+// Start or Listen.
+type Server struct {
+	// Addr is the address the server listens on. After the server has
+	// started it holds the actual address, suitable for
+	// apns.Client.ConfigureEndpoint.
+	Addr string
+
+	// Certificate is the TLS certificate presented to clients. If unset, a
+	// self-signed certificate for localhost is generated when the server
+	// starts.
+	Certificate tls.Certificate
+
+	// TokenKeys maps key IDs to the public keys used to verify provider
+	// tokens. If empty, any well-formed ES256 token is accepted.
+	TokenKeys map[string]*ecdsa.PublicKey
+
+	// Topics lists the topics notifications may be sent to. If empty, any
+	// topic is accepted.
+	Topics []string
+
+	// Rules force specific responses or connection failures for matching
+	// notifications.
+	Rules []Rule
+
+	// Seed seeds the random choices made for rules with a Percentage, so
+	// that a run can be repeated exactly.
+	Seed int64
+
+	// Log receives a line for every notification received.
+	Log io.Writer
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	received []Notification
+	ruleHits map[int]int
+	rand     *mathrand.Rand
+	wg       sync.WaitGroup
+	logMu    sync.Mutex
+}
+
+func NewServer() *Server {
+	return &Server{
+		Addr: DefaultAddr,
+	}
+}
+
+// Start listens on a random port on the loopback interface and serves
+// requests in the background until Close is called.
+func (s *Server) Start() error {
+	s.Addr = "127.0.0.1:0"
+	return s.Listen()
+}
+
+// Listen listens on s.Addr and serves requests in the background until Close
+// is called.
+func (s *Server) Listen() error {
+	if len(s.Certificate.Certificate) == 0 {
+		cert, err := GenerateCertificate("localhost", "127.0.0.1", "::1")
+		if err != nil {
+			return err
+		}
+		s.Certificate = cert
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{s.Certificate},
+		ClientAuth:   tls.RequestClientCert,
+		NextProtos:   []string{http2.NextProtoTLS},
+	}
+
+	listener, err := tls.Listen("tcp", s.Addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.conns = make(map[net.Conn]struct{})
+	s.Addr = listener.Addr().String()
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.acceptLoop(listener)
+
+	return nil
+}
+
+// Close stops the server and closes all open connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+		s.listener = nil
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// RootCAs returns a certificate pool that trusts the server's certificate,
+// suitable for apns.Client.ConfigureRootCAs.
+func (s *Server) RootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, der := range s.Certificate.Certificate {
+		if cert, err := x509.ParseCertificate(der); err == nil {
+			pool.AddCert(cert)
+		}
+	}
+	return pool
+}
+
+// CertificatePEM returns the server's certificate chain in PEM format.
+func (s *Server) CertificatePEM() []byte {
+	var data []byte
+	for _, der := range s.Certificate.Certificate {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	return data
+}
+
+// Received returns the notifications the server has received so far, in
+// order, including those it rejected or reset the connection for. Check
+// StatusCode to tell them apart.
+func (s *Server) Received() []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	received := make([]Notification, len(s.received))
+	copy(received, s.received)
+	return received
+}
+
+func (s *Server) acceptLoop(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		_ = conn.Close()
+
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+	}
+
+	// Each connection gets its own server so that a rule can shut down a
+	// single connection with a GOAWAY frame.
+	h1Server := &http.Server{}
+	h2Server := &http2.Server{}
+	if err := http2.ConfigureServer(h1Server, h2Server); err != nil {
+		return
+	}
+
+	state := &connState{
+		conn: conn,
+		goAway: func() {
+			_ = h1Server.Shutdown(context.Background())
+		},
+	}
+
+	h2Server.ServeConn(conn, &http2.ServeConnOpts{
+		Context:    context.WithValue(context.Background(), connStateKey{}, state),
+		BaseConfig: h1Server,
+		Handler:    http.HandlerFunc(s.handle),
+	})
+}
+
+type connStateKey struct{}
+
+// connState gives request handlers control over their connection.
+type connState struct {
+	conn   net.Conn
+	goAway func()
+}
+
+// random must be called with s.mu held.
+func (s *Server) random() *mathrand.Rand {
+	if s.rand == nil {
+		s.rand = mathrand.New(mathrand.NewSource(s.Seed))
+	}
+	return s.rand
+}
+
+// GenerateCertificate creates a self-signed TLS certificate valid for the
+// given host names and IP addresses.
+func GenerateCertificate(hosts ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: "apnstool mock server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
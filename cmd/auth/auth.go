@@ -14,6 +14,7 @@ func GetCommand() *cobra.Command {
 	}
 
 	authCmd.AddCommand(NewAuthGenerateTokenCommand())
+	authCmd.AddCommand(NewAuthInspectCertCommand())
 
 	return authCmd
 }
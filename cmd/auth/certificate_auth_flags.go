@@ -5,23 +5,42 @@
 package auth
 
 import (
+	"crypto/tls"
+
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/cmdio"
 	"github.com/spf13/pflag"
 )
 
 const (
 	CertificateFileFlag = "cert-file"
-	CertificateFileDesc = "path to .p12 file containing APNs certificate"
+	CertificateFileDesc = "path to .p12 or PEM file containing APNs certificate (PEM may include the key and chain)"
+
+	CertificateKeyFileFlag = "cert-key-file"
+	CertificateKeyFileDesc = "path to PEM file containing the certificate's private key, if not in --cert-file"
 
 	CertificatePasswordFlag = "cert-password"
-	CertificatePasswordDesc = "password to decrypt the .p12 file (optional)"
+	CertificatePasswordDesc = "password to decrypt the .p12 file or encrypted private key (optional)"
 )
 
 type CertificateAuth struct {
-	CertificateFile     string
-	CertificatePassword string
+	CertificateFile     string `json:"cert-file,omitempty" yaml:"cert-file,omitempty"`
+	CertificateKeyFile  string `json:"cert-key-file,omitempty" yaml:"cert-key-file,omitempty"`
+	CertificatePassword string `json:"cert-password,omitempty" yaml:"cert-password,omitempty"`
 }
 
 func BindCertificateAuthFlags(flags *pflag.FlagSet, certificateAuth *CertificateAuth) {
 	flags.StringVar(&certificateAuth.CertificateFile, CertificateFileFlag, certificateAuth.CertificateFile, CertificateFileDesc)
+	flags.StringVar(&certificateAuth.CertificateKeyFile, CertificateKeyFileFlag, certificateAuth.CertificateKeyFile, CertificateKeyFileDesc)
 	flags.StringVar(&certificateAuth.CertificatePassword, CertificatePasswordFlag, certificateAuth.CertificatePassword, CertificatePasswordDesc)
+	cmdio.BindEnv(flags, CertificateFileFlag, CertificateKeyFileFlag, CertificatePasswordFlag)
+}
+
+// Load loads the certificate, its chain and its private key.
+func (certificateAuth *CertificateAuth) Load() (tls.Certificate, error) {
+	return apns.LoadCertificateFromFiles(
+		certificateAuth.CertificateFile,
+		certificateAuth.CertificateKeyFile,
+		certificateAuth.CertificatePassword,
+	)
 }
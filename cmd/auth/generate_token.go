@@ -5,6 +5,8 @@
 package auth
 
 import (
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/brannon/apnstool/apns"
@@ -49,18 +51,43 @@ func (cmd *AuthGenerateTokenCmd) Prepare(args []string) error {
 }
 
 func (cmd *AuthGenerateTokenCmd) Run() error {
+	issuedAt := time.Now()
+
 	token, err := apns.GenerateJWTFromKeyFile(
 		cmd.TokenAuth.KeyFile,
 		cmd.TokenAuth.KeyId,
 		cmd.TokenAuth.TeamId,
-		time.Now(),
+		issuedAt,
 		cmd.TokenAuth.ExpiresAfter,
 	)
 	if err != nil {
 		return err
 	}
 
-	cmd.io.Outf("%s\n", token)
+	header, claims, err := apns.DecodeJWT(token)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return cmd.io.Output(&GenerateTokenOutput{
+		Token:     token,
+		Header:    header,
+		Claims:    claims,
+		IssuedAt:  issuedAt.UTC().Format(time.RFC3339),
+		ExpiresAt: issuedAt.Add(cmd.TokenAuth.ExpiresAfter).UTC().Format(time.RFC3339),
+	})
+}
+
+// GenerateTokenOutput holds a provider token and its claims. Its text form is
+// the bare token, so it can be used in scripts.
+type GenerateTokenOutput struct {
+	Token     string                 `json:"token" yaml:"token"`
+	Header    map[string]interface{} `json:"header" yaml:"header"`
+	Claims    map[string]interface{} `json:"claims" yaml:"claims"`
+	IssuedAt  string                 `json:"issued_at" yaml:"issued_at"`
+	ExpiresAt string                 `json:"expires_at" yaml:"expires_at"`
+}
+
+func (output *GenerateTokenOutput) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s\n", output.Token)
 }
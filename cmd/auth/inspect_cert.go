@@ -0,0 +1,121 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ExpiryWarningFlag    = "expiry-warning"
+	ExpiryWarningDefault = 30 * 24 * time.Hour
+	ExpiryWarningDesc    = "warn if the certificate expires within this time"
+)
+
+type AuthInspectCertCmd struct {
+	CertificateAuth CertificateAuth
+	ExpiryWarning   time.Duration
+
+	io cmdio.CmdIO
+}
+
+func NewAuthInspectCertCommand() *cobra.Command {
+	authInspectCert := &AuthInspectCertCmd{}
+
+	cobraCmd := &cobra.Command{
+		Use:   "inspect-cert",
+		Short: "Show the details of an APNs certificate",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			authInspectCert.io = cmdio.NewCmdIO(cmd.OutOrStdout())
+
+			return authInspectCert.Run()
+		},
+	}
+
+	BindCertificateAuthFlags(cobraCmd.Flags(), &authInspectCert.CertificateAuth)
+	cobraCmd.Flags().DurationVar(&authInspectCert.ExpiryWarning, ExpiryWarningFlag, ExpiryWarningDefault, ExpiryWarningDesc)
+	_ = cobraCmd.MarkFlagRequired(CertificateFileFlag)
+
+	return cobraCmd
+}
+
+func (cmd *AuthInspectCertCmd) Run() error {
+	cert, err := cmd.CertificateAuth.Load()
+	if err != nil {
+		return cmdio.NewExitCodeError(cmdio.ExitAuthError, err)
+	}
+
+	info, err := apns.InspectCertificate(cert.Leaf)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	output := &InspectCertOutput{CertificateInfo: *info}
+
+	expiryErr := apns.CheckCertificateExpiry(cert, now)
+	if expiryErr != nil {
+		output.Warnings = append(output.Warnings, expiryErr.Error())
+	} else if remaining := info.NotAfter.Sub(now); remaining < cmd.ExpiryWarning {
+		output.Warnings = append(output.Warnings,
+			fmt.Sprintf("certificate expires in %d days, on %s", int(remaining.Hours()/24), info.NotAfter.Format(time.RFC3339)))
+	}
+
+	if info.Environment == "" {
+		output.Warnings = append(output.Warnings, "certificate has no APNs environment extension; it may not be an APNs certificate")
+	}
+
+	if err := cmd.io.Output(output); err != nil {
+		return err
+	}
+
+	if expiryErr != nil {
+		return cmdio.NewExitCodeError(cmdio.ExitAuthError, errors.New("certificate is not valid"))
+	}
+
+	return nil
+}
+
+// InspectCertOutput describes a certificate and whether it is usable now.
+type InspectCertOutput struct {
+	apns.CertificateInfo `yaml:",inline"`
+	Warnings             []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+func (output *InspectCertOutput) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Subject:     %s\n", output.Subject)
+	if output.UID != "" {
+		fmt.Fprintf(w, "UID:         %s\n", output.UID)
+	}
+	if len(output.Topics) > 0 {
+		topics := make([]string, len(output.Topics))
+		for i, topic := range output.Topics {
+			topics[i] = topic.Topic
+			if len(topic.PushTypes) > 0 {
+				topics[i] += " (" + strings.Join(topic.PushTypes, ", ") + ")"
+			}
+		}
+		fmt.Fprintf(w, "Topics:      %s\n", strings.Join(topics, "\n             "))
+	}
+	if output.Environment != "" {
+		fmt.Fprintf(w, "Environment: %s\n", output.Environment)
+	}
+	fmt.Fprintf(w, "Issuer:      %s\n", output.Issuer)
+	fmt.Fprintf(w, "Valid from:  %s\n", output.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(w, "Valid until: %s\n", output.NotAfter.Format(time.RFC3339))
+
+	for _, warning := range output.Warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+}
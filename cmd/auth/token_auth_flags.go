@@ -7,6 +7,7 @@ package auth
 import (
 	"time"
 
+	"github.com/brannon/apnstool/cmdio"
 	"github.com/spf13/pflag"
 )
 
@@ -26,10 +27,10 @@ const (
 )
 
 type TokenAuth struct {
-	KeyFile      string
-	KeyId        string
-	TeamId       string
-	ExpiresAfter time.Duration
+	KeyFile      string        `json:"key-file,omitempty" yaml:"key-file,omitempty"`
+	KeyId        string        `json:"key-id,omitempty" yaml:"key-id,omitempty"`
+	TeamId       string        `json:"team-id,omitempty" yaml:"team-id,omitempty"`
+	ExpiresAfter time.Duration `json:"expires-after,omitempty" yaml:"expires-after,omitempty"`
 }
 
 func BindTokenAuthFlags(flags *pflag.FlagSet, tokenAuth *TokenAuth) {
@@ -37,4 +38,5 @@ func BindTokenAuthFlags(flags *pflag.FlagSet, tokenAuth *TokenAuth) {
 	flags.StringVar(&tokenAuth.KeyId, KeyIdFlag, tokenAuth.KeyId, KeyIdDesc)
 	flags.StringVar(&tokenAuth.TeamId, TeamIdFlag, tokenAuth.TeamId, TeamIdDesc)
 	flags.DurationVar(&tokenAuth.ExpiresAfter, ExpiresAfterFlag, ExpiresAfterDefault, ExpiresAfterDesc)
+	cmdio.BindEnv(flags, KeyFileFlag, KeyIdFlag, TeamIdFlag, ExpiresAfterFlag)
 }
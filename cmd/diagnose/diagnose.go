@@ -0,0 +1,360 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package diagnose
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/cmd/auth"
+	"github.com/brannon/apnstool/cmd/send"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/brannon/apnstool/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ProductionEndpointFlag    = "production-endpoint"
+	ProductionEndpointDefault = apns.ProductionEndpoint
+	ProductionEndpointDesc    = "APNs host[:port] used as the production environment"
+
+	SandboxEndpointFlag    = "sandbox-endpoint"
+	SandboxEndpointDefault = apns.SandboxEndpoint
+	SandboxEndpointDesc    = "APNs host[:port] used as the sandbox environment"
+)
+
+const diagnoseLong = `Send a notification to a device through both the sandbox and production
+environments with every configured credential (token and certificate), then
+print the results and what they mean.
+
+Unless --data is given, a silent background notification is sent, so a device
+that receives it shows nothing to the user.`
+
+type DiagnoseCmd struct {
+	send.DataFlags
+
+	AppId              string
+	CAFile             string
+	CertificateAuth    auth.CertificateAuth
+	DeviceToken        string
+	ProductionEndpoint string
+	SandboxEndpoint    string
+	Timeout            time.Duration
+	TokenAuth          auth.TokenAuth
+
+	IO cmdio.CmdIO
+}
+
+func GetCommand() *cobra.Command {
+	cmd := &DiagnoseCmd{}
+
+	cobraCmd := &cobra.Command{
+		Use:   "diagnose",
+		Short: "Find out why notifications do not reach a device",
+		Long:  diagnoseLong,
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.IO = cmdio.NewCmdIO(c.OutOrStdout())
+
+			return cmd.Run()
+		},
+	}
+
+	flags := cobraCmd.Flags()
+	auth.BindTokenAuthFlags(flags, &cmd.TokenAuth)
+	auth.BindCertificateAuthFlags(flags, &cmd.CertificateAuth)
+	send.BindDataFlags(flags, &cmd.DataFlags)
+	flags.StringVar(&cmd.AppId, send.AppIdFlag, send.AppIdDefault, send.AppIdDesc)
+	flags.StringVar(&cmd.CAFile, send.CAFileFlag, send.CAFileDefault, send.CAFileDesc)
+	flags.StringVar(&cmd.DeviceToken, send.DeviceTokenFlag, send.DeviceTokenDefault, send.DeviceTokenDesc)
+	flags.StringVar(&cmd.ProductionEndpoint, ProductionEndpointFlag, ProductionEndpointDefault, ProductionEndpointDesc)
+	flags.StringVar(&cmd.SandboxEndpoint, SandboxEndpointFlag, SandboxEndpointDefault, SandboxEndpointDesc)
+	flags.DurationVar(&cmd.Timeout, send.TimeoutFlag, send.TimeoutDefault, send.TimeoutDesc)
+	cmdio.BindEnv(flags,
+		send.AppIdFlag,
+		send.CAFileFlag,
+		send.DeviceTokenFlag,
+		send.TimeoutFlag,
+	)
+
+	_ = cobraCmd.MarkFlagRequired(send.AppIdFlag)
+	_ = cobraCmd.MarkFlagRequired(send.DeviceTokenFlag)
+
+	return cobraCmd
+}
+
+// credential is one way of authenticating with APNs. A credential with
+// missing flags is reported but not tried.
+type credential struct {
+	name        string
+	certificate bool
+	configure   func(client apns.Client)
+	missing     []string
+}
+
+// DiagnoseResult is the outcome of sending with one credential to one
+// environment.
+type DiagnoseResult struct {
+	Credential  string `json:"credential" yaml:"credential"`
+	Environment string `json:"environment" yaml:"environment"`
+	StatusCode  int    `json:"status_code,omitempty" yaml:"status_code,omitempty"`
+	Reason      string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Timestamp   string `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+	Error       string `json:"error,omitempty" yaml:"error,omitempty"`
+	Skipped     string `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+
+	certificate bool
+}
+
+func (result *DiagnoseResult) success() bool {
+	return result.StatusCode == 200
+}
+
+func (result *DiagnoseResult) status() string {
+	switch {
+	case result.Skipped != "":
+		return "skipped: " + result.Skipped
+	case result.Error != "":
+		return "error: " + result.Error
+	case result.success():
+		return "200 OK"
+	}
+	return fmt.Sprintf("%d %s", result.StatusCode, result.Reason)
+}
+
+// DiagnoseOutput reports how a device token fared with each environment and
+// credential that was tried.
+type DiagnoseOutput struct {
+	DeviceToken string            `json:"device_token" yaml:"device_token"`
+	AppId       string            `json:"app_id" yaml:"app_id"`
+	Results     []*DiagnoseResult `json:"results" yaml:"results"`
+	Diagnosis   []string          `json:"diagnosis" yaml:"diagnosis"`
+}
+
+func (output *DiagnoseOutput) WriteText(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "CREDENTIAL\t%s\t%s\n", config.EnvironmentSandbox, config.EnvironmentProduction)
+
+	for i := 0; i+1 < len(output.Results); i += 2 {
+		sandbox, production := output.Results[i], output.Results[i+1]
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", sandbox.Credential, sandbox.status(), production.status())
+	}
+	_ = tw.Flush()
+
+	fmt.Fprint(w, "\nDiagnosis:\n")
+	for _, line := range output.Diagnosis {
+		fmt.Fprintf(w, "- %s\n", line)
+	}
+}
+
+func (cmd *DiagnoseCmd) Run() error {
+	headers, content, err := cmd.buildNotification()
+	if err != nil {
+		return err
+	}
+
+	credentials, err := cmd.credentials()
+	if err != nil {
+		return err
+	}
+	usable := 0
+	for _, credential := range credentials {
+		if len(credential.missing) == 0 {
+			usable++
+		}
+	}
+	if usable == 0 {
+		return fmt.Errorf("no complete credentials configured (use --%s, --%s and --%s, or --%s)",
+			auth.KeyFileFlag, auth.KeyIdFlag, auth.TeamIdFlag, auth.CertificateFileFlag)
+	}
+
+	var rootCAs *x509.CertPool
+	if cmd.CAFile != "" {
+		if rootCAs, err = send.LoadCAFile(cmd.CAFile); err != nil {
+			return err
+		}
+	}
+
+	environments := []struct {
+		name     string
+		endpoint string
+	}{
+		{config.EnvironmentSandbox, cmd.SandboxEndpoint},
+		{config.EnvironmentProduction, cmd.ProductionEndpoint},
+	}
+
+	output := &DiagnoseOutput{
+		DeviceToken: cmd.DeviceToken,
+		AppId:       cmd.AppId,
+	}
+
+	for _, credential := range credentials {
+		for _, environment := range environments {
+			if len(credential.missing) > 0 {
+				output.Results = append(output.Results, &DiagnoseResult{
+					Credential:  credential.name,
+					Environment: environment.name,
+					Skipped:     "missing " + strings.Join(credential.missing, ", "),
+				})
+				continue
+			}
+
+			client := apns.NewClient()
+			client.ConfigureEndpoint(environment.endpoint)
+			if rootCAs != nil {
+				client.ConfigureRootCAs(rootCAs)
+			}
+			credential.configure(client)
+
+			result := cmd.send(client, headers, content)
+			result.Credential = credential.name
+			result.Environment = environment.name
+			result.certificate = credential.certificate
+			output.Results = append(output.Results, result)
+
+			_ = client.Close()
+		}
+	}
+
+	output.Diagnosis = diagnose(output.Results)
+
+	if err := cmd.IO.Output(output); err != nil {
+		return err
+	}
+
+	for _, result := range output.Results {
+		if result.success() {
+			return nil
+		}
+	}
+
+	return cmdio.NewExitCodeError(cmdio.ExitRejected, errors.New("the notification was not accepted with any credential or environment"))
+}
+
+func (cmd *DiagnoseCmd) buildNotification() (apns.Headers, []byte, error) {
+	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
+
+	if cmd.DataFlags.IsSet() {
+		_, data, err := cmd.DataFlags.Read(cmd.IO.Stdin())
+		if err != nil {
+			return nil, nil, err
+		}
+		notificationBuilder.Merge(data)
+	} else {
+		notificationBuilder.SetContentAvailable(true)
+	}
+
+	return notificationBuilder.Build()
+}
+
+// credentials returns the configured credentials. Unlike the send commands,
+// which use a token in preference to a certificate, both are tried. A token
+// credential without its key file, key ID or team ID is returned with the
+// missing flags.
+func (cmd *DiagnoseCmd) credentials() ([]credential, error) {
+	var credentials []credential
+
+	tokenFlags := []struct {
+		name  string
+		value string
+	}{
+		{auth.KeyFileFlag, cmd.TokenAuth.KeyFile},
+		{auth.KeyIdFlag, cmd.TokenAuth.KeyId},
+		{auth.TeamIdFlag, cmd.TokenAuth.TeamId},
+	}
+
+	var missing []string
+	for _, flag := range tokenFlags {
+		if flag.value == "" {
+			missing = append(missing, "--"+flag.name)
+		}
+	}
+
+	if len(missing) > 0 && len(missing) < len(tokenFlags) {
+		credentials = append(credentials, credential{
+			name:    "token",
+			missing: missing,
+		})
+	} else if len(missing) == 0 {
+		tokenProvider, err := apns.NewKeyTokenProviderFromFile(
+			cmd.TokenAuth.KeyFile,
+			cmd.TokenAuth.KeyId,
+			cmd.TokenAuth.TeamId,
+		)
+		if err != nil {
+			return nil, cmdio.NewExitCodeError(cmdio.ExitAuthError, err)
+		}
+
+		credentials = append(credentials, credential{
+			name: fmt.Sprintf("token (key %s, team %s)", cmd.TokenAuth.KeyId, cmd.TokenAuth.TeamId),
+			configure: func(client apns.Client) {
+				client.ConfigureTokenProvider(tokenProvider)
+			},
+		})
+	}
+
+	if cmd.CertificateAuth.CertificateFile != "" {
+		cert, err := cmd.CertificateAuth.Load()
+		if err != nil {
+			return nil, cmdio.NewExitCodeError(cmdio.ExitAuthError, err)
+		}
+
+		if err := apns.CheckCertificateExpiry(cert, time.Now()); err != nil {
+			return nil, cmdio.NewExitCodeError(cmdio.ExitAuthError, err)
+		}
+
+		name := "certificate"
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+			name = fmt.Sprintf("certificate (%s)", leaf.Subject.CommonName)
+		}
+
+		credentials = append(credentials, credential{
+			name:        name,
+			certificate: true,
+			configure: func(client apns.Client) {
+				client.ConfigureCertificateAuth(cert)
+			},
+		})
+	}
+
+	return credentials, nil
+}
+
+func (cmd *DiagnoseCmd) send(client apns.Client, headers apns.Headers, content []byte) *DiagnoseResult {
+	ctx := context.Background()
+	if cmd.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cmd.Timeout)
+		defer cancel()
+	}
+
+	result, err := client.SendContext(ctx, cmd.DeviceToken, headers, content)
+	if err != nil {
+		// The request URL adds nothing to the table.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return &DiagnoseResult{Error: err.Error()}
+	}
+
+	diagnoseResult := &DiagnoseResult{
+		StatusCode: result.StatusCode,
+		Reason:     string(result.ErrorReason()),
+	}
+	if timestamp := result.Timestamp(); !timestamp.IsZero() {
+		diagnoseResult.Timestamp = timestamp.Format(time.RFC3339)
+	}
+
+	return diagnoseResult
+}
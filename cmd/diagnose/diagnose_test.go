@@ -0,0 +1,141 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package diagnose
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brannon/apnstool/cmd/auth"
+	"github.com/brannon/apnstool/config"
+)
+
+func TestDiagnose(t *testing.T) {
+	const (
+		sandbox    = config.EnvironmentSandbox
+		production = config.EnvironmentProduction
+	)
+
+	result := func(environment string, statusCode int, reason string) *DiagnoseResult {
+		return &DiagnoseResult{Credential: "token", Environment: environment, StatusCode: statusCode, Reason: reason}
+	}
+
+	tests := []struct {
+		name    string
+		results []*DiagnoseResult
+		want    []string
+	}{
+		{
+			name:    "sandbox token",
+			results: []*DiagnoseResult{result(sandbox, 200, ""), result(production, 400, "BadDeviceToken")},
+			want: []string{
+				"The device token is a sandbox (development) token. Send it with --sandbox; apps built and run from Xcode use the sandbox environment.",
+				"The notification was accepted in the sandbox environment with token.",
+			},
+		},
+		{
+			name:    "production token",
+			results: []*DiagnoseResult{result(sandbox, 400, "BadDeviceToken"), result(production, 410, "Unregistered")},
+			want: []string{
+				"The device token is a production token. Send it without --sandbox; TestFlight, Ad Hoc and App Store builds use the production environment.",
+				"The device token is no longer active: the app was removed or the token changed. Get a fresh token from the device.",
+			},
+		},
+		{
+			name:    "bad token in both environments",
+			results: []*DiagnoseResult{result(sandbox, 400, "BadDeviceToken"), result(production, 400, "BadDeviceToken")},
+			want: []string{
+				"APNs rejects the device token in both environments. Check that it was copied completely and is the hex APNs token from the app, not a token from another push service.",
+			},
+		},
+		{
+			name:    "invalid provider token",
+			results: []*DiagnoseResult{result(sandbox, 403, "InvalidProviderToken"), result(production, 403, "InvalidProviderToken")},
+			want: []string{
+				"Token authentication failed (InvalidProviderToken). Check that the .p8 key, key ID and team ID belong together and the system clock is correct.",
+			},
+		},
+		{
+			name: "certificate topic",
+			results: []*DiagnoseResult{
+				{Credential: "certificate", Environment: sandbox, StatusCode: 400, Reason: "TopicDisallowed", certificate: true},
+				{Credential: "certificate", Environment: production, StatusCode: 400, Reason: "BadCertificateEnvironment", certificate: true},
+			},
+			want: []string{
+				"The certificate's topic does not match the app ID. Use a certificate issued for this bundle ID, or check --app-id.",
+				"The certificate cannot be used in the production environment; it was issued for the other one.",
+			},
+		},
+		{
+			name: "unreachable",
+			results: []*DiagnoseResult{
+				{Credential: "token", Environment: sandbox, Error: "connection refused"},
+				result(production, 503, "ServiceUnavailable"),
+			},
+			want: []string{
+				"Could not reach the sandbox environment: connection refused.",
+				"APNs reported a temporary problem in the production environment (ServiceUnavailable). Try again later.",
+			},
+		},
+		{
+			name: "incomplete credential",
+			results: []*DiagnoseResult{
+				{Credential: "token", Environment: sandbox, Skipped: "missing --team-id"},
+				{Credential: "token", Environment: production, Skipped: "missing --team-id"},
+			},
+			want: []string{
+				"The token credential was not tried because it is incomplete (missing --team-id).",
+			},
+		},
+		{
+			name:    "no single cause",
+			results: []*DiagnoseResult{result(sandbox, 0, ""), result(production, 0, "")},
+			want:    []string{"The results do not point to a single cause; see the table above."},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := diagnose(test.results); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCredentialsIncompleteToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   auth.TokenAuth
+		missing []string
+	}{
+		{name: "none"},
+		{name: "key file only", token: auth.TokenAuth{KeyFile: "key.p8"}, missing: []string{"--key-id", "--team-id"}},
+		{name: "no team", token: auth.TokenAuth{KeyFile: "key.p8", KeyId: "KEYID"}, missing: []string{"--team-id"}},
+		{name: "no key file", token: auth.TokenAuth{KeyId: "KEYID", TeamId: "TEAMID"}, missing: []string{"--key-file"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := &DiagnoseCmd{TokenAuth: test.token}
+
+			credentials, err := cmd.credentials()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.missing == nil {
+				if len(credentials) != 0 {
+					t.Errorf("got %d credentials, want none", len(credentials))
+				}
+				return
+			}
+
+			if len(credentials) != 1 || !reflect.DeepEqual(credentials[0].missing, test.missing) {
+				t.Fatalf("got credentials %+v, want one missing %v", credentials, test.missing)
+			}
+		})
+	}
+}
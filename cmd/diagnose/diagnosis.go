@@ -0,0 +1,135 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package diagnose
+
+import (
+	"fmt"
+
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/config"
+)
+
+// tokenState is what the results for one environment say about the device
+// token.
+type tokenState int
+
+const (
+	tokenUnknown tokenState = iota
+	tokenAccepted
+	tokenRejected
+)
+
+// diagnose explains the results in plain language.
+func diagnose(results []*DiagnoseResult) []string {
+	d := &diagnosis{seen: make(map[string]bool)}
+
+	states := map[string]tokenState{}
+	for _, result := range results {
+		state := stateOf(result)
+		if state > states[result.Environment] {
+			states[result.Environment] = state
+		}
+	}
+
+	sandbox, production := states[config.EnvironmentSandbox], states[config.EnvironmentProduction]
+	switch {
+	case sandbox == tokenAccepted && production == tokenRejected:
+		d.add("The device token is a sandbox (development) token. Send it with --sandbox; apps built and run from Xcode use the sandbox environment.")
+	case production == tokenAccepted && sandbox == tokenRejected:
+		d.add("The device token is a production token. Send it without --sandbox; TestFlight, Ad Hoc and App Store builds use the production environment.")
+	case sandbox == tokenRejected && production == tokenRejected:
+		d.add("APNs rejects the device token in both environments. Check that it was copied completely and is the hex APNs token from the app, not a token from another push service.")
+	}
+
+	for _, result := range results {
+		d.explain(result)
+	}
+
+	for _, result := range results {
+		if result.success() {
+			d.add(fmt.Sprintf("The notification was accepted in the %s environment with %s.", result.Environment, result.Credential))
+		}
+	}
+
+	if len(d.lines) == 0 {
+		d.add("The results do not point to a single cause; see the table above.")
+	}
+
+	return d.lines
+}
+
+type diagnosis struct {
+	lines []string
+	seen  map[string]bool
+}
+
+func (d *diagnosis) add(line string) {
+	if !d.seen[line] {
+		d.seen[line] = true
+		d.lines = append(d.lines, line)
+	}
+}
+
+// stateOf reports whether APNs accepted the device token in the result's
+// environment. Reasons APNs only returns after validating the token count as
+// accepted.
+func stateOf(result *DiagnoseResult) tokenState {
+	if result.success() {
+		return tokenAccepted
+	}
+
+	switch apns.ErrorReason(result.Reason) {
+	case apns.ReasonBadDeviceToken:
+		return tokenRejected
+	case apns.ReasonDeviceTokenNotForTopic, apns.ReasonUnregistered, apns.ReasonExpiredToken:
+		return tokenAccepted
+	}
+
+	return tokenUnknown
+}
+
+func (d *diagnosis) explain(result *DiagnoseResult) {
+	if result.Skipped != "" {
+		d.add(fmt.Sprintf("The %s credential was not tried because it is incomplete (%s).", result.Credential, result.Skipped))
+		return
+	}
+	if result.Error != "" {
+		d.add(fmt.Sprintf("Could not reach the %s environment: %s.", result.Environment, result.Error))
+		return
+	}
+
+	reason := apns.ErrorReason(result.Reason)
+
+	switch reason {
+	case apns.ReasonDeviceTokenNotForTopic:
+		d.add("The device token belongs to a different app. Check that --app-id is the bundle ID of the app that registered the token.")
+	case apns.ReasonUnregistered, apns.ReasonExpiredToken:
+		since := ""
+		if result.Timestamp != "" {
+			since = " since " + result.Timestamp
+		}
+		d.add(fmt.Sprintf("The device token is no longer active%s: the app was removed or the token changed. Get a fresh token from the device.", since))
+	case apns.ReasonInvalidProviderToken, apns.ReasonExpiredProviderToken, apns.ReasonMissingProviderToken:
+		d.add(fmt.Sprintf("Token authentication failed (%s). Check that the .p8 key, key ID and team ID belong together and the system clock is correct.", reason))
+	case apns.ReasonBadCertificateEnvironment:
+		d.add(fmt.Sprintf("The certificate cannot be used in the %s environment; it was issued for the other one.", result.Environment))
+	case apns.ReasonBadCertificate:
+		d.add("APNs rejected the certificate. It may be revoked, expired, or not an APNs certificate.")
+	case apns.ReasonTopicDisallowed, apns.ReasonBadTopic, apns.ReasonMissingTopic:
+		if result.certificate {
+			d.add("The certificate's topic does not match the app ID. Use a certificate issued for this bundle ID, or check --app-id.")
+		} else {
+			d.add("The key's team is not allowed to send to this app ID. Check --app-id and that --team-id owns the app.")
+		}
+	case apns.ReasonForbidden:
+		d.add(fmt.Sprintf("APNs refused %s (Forbidden). Check that the credential is still valid in the developer account.", result.Credential))
+	default:
+		if !result.success() && reason.IsKnown() && !reason.IsRetryable() && stateOf(result) == tokenUnknown {
+			d.add(fmt.Sprintf("APNs rejected the notification itself (%s): %s", reason, reason.Description()))
+		} else if reason.IsRetryable() {
+			d.add(fmt.Sprintf("APNs reported a temporary problem in the %s environment (%s). Try again later.", result.Environment, reason))
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package mockserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/apnstest"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/spf13/cobra"
+)
+
+const (
+	AddrFlag    = "addr"
+	AddrDefault = apnstest.DefaultAddr
+	AddrDesc    = "address to listen on"
+
+	CertOutFlag    = "cert-out"
+	CertOutDefault = ""
+	CertOutDesc    = "write the server's TLS certificate to this path (for use with 'send --ca-file')"
+
+	RulesFileFlag    = "rules"
+	RulesFileDefault = ""
+	RulesFileDesc    = "path to JSON file with failure injection rules"
+
+	SeedFlag    = "seed"
+	SeedDefault = 0
+	SeedDesc    = "seed for percentage-based rules"
+
+	TLSCertFileFlag    = "tls-cert-file"
+	TLSCertFileDefault = ""
+	TLSCertFileDesc    = "path to PEM TLS certificate (a self-signed certificate is generated if not set)"
+
+	TLSKeyFileFlag    = "tls-key-file"
+	TLSKeyFileDefault = ""
+	TLSKeyFileDesc    = "path to PEM TLS private key"
+
+	TokenKeyFileFlag    = "token-key-file"
+	TokenKeyFileDefault = ""
+	TokenKeyFileDesc    = "path to .p8 key used to verify provider tokens (any token is accepted if not set)"
+
+	TokenKeyIdFlag    = "token-key-id"
+	TokenKeyIdDefault = ""
+	TokenKeyIdDesc    = "key ID of the --token-key-file key"
+
+	TopicFlag = "topic"
+	TopicDesc = "topic to accept notifications for (repeatable; any topic is accepted if not set)"
+)
+
+type MockServerCmd struct {
+	Addr         string
+	CertOut      string
+	RulesFile    string
+	Seed         int64
+	TLSCertFile  string
+	TLSKeyFile   string
+	TokenKeyFile string
+	TokenKeyId   string
+	Topics       []string
+
+	IO cmdio.CmdIO
+}
+
+func GetCommand() *cobra.Command {
+	cmd := &MockServerCmd{}
+
+	cobraCmd := &cobra.Command{
+		Use:   "mock-server",
+		Short: "Run a local mock APNs server for offline testing",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.IO = cmdio.NewCmdIO(c.OutOrStdout())
+
+			return cmd.Run()
+		},
+	}
+
+	flags := cobraCmd.Flags()
+	flags.StringVar(&cmd.Addr, AddrFlag, AddrDefault, AddrDesc)
+	flags.StringVar(&cmd.CertOut, CertOutFlag, CertOutDefault, CertOutDesc)
+	flags.StringVar(&cmd.RulesFile, RulesFileFlag, RulesFileDefault, RulesFileDesc)
+	flags.Int64Var(&cmd.Seed, SeedFlag, SeedDefault, SeedDesc)
+	flags.StringVar(&cmd.TLSCertFile, TLSCertFileFlag, TLSCertFileDefault, TLSCertFileDesc)
+	flags.StringVar(&cmd.TLSKeyFile, TLSKeyFileFlag, TLSKeyFileDefault, TLSKeyFileDesc)
+	flags.StringVar(&cmd.TokenKeyFile, TokenKeyFileFlag, TokenKeyFileDefault, TokenKeyFileDesc)
+	flags.StringVar(&cmd.TokenKeyId, TokenKeyIdFlag, TokenKeyIdDefault, TokenKeyIdDesc)
+	flags.StringArrayVar(&cmd.Topics, TopicFlag, nil, TopicDesc)
+
+	return cobraCmd
+}
+
+func (cmd *MockServerCmd) Run() error {
+	server := apnstest.NewServer()
+	server.Addr = cmd.Addr
+	server.Topics = cmd.Topics
+	server.Log = cmd.IO.Stdout()
+	server.Seed = cmd.Seed
+
+	if cmd.RulesFile != "" {
+		rules, err := apnstest.LoadRulesFromFile(cmd.RulesFile)
+		if err != nil {
+			return err
+		}
+		server.Rules = rules
+	}
+
+	if cmd.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cmd.TLSCertFile, cmd.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		server.Certificate = cert
+	}
+
+	if cmd.TokenKeyFile != "" {
+		if cmd.TokenKeyId == "" {
+			return fmt.Errorf("--%s is required with --%s", TokenKeyIdFlag, TokenKeyFileFlag)
+		}
+
+		key, err := apns.LoadKeyFromFile(cmd.TokenKeyFile)
+		if err != nil {
+			return err
+		}
+		server.TokenKeys = map[string]*ecdsa.PublicKey{
+			cmd.TokenKeyId: &key.PublicKey,
+		}
+	}
+
+	if err := server.Listen(); err != nil {
+		return err
+	}
+	defer server.Close()
+
+	if cmd.CertOut != "" {
+		if err := ioutil.WriteFile(cmd.CertOut, server.CertificatePEM(), 0644); err != nil {
+			return err
+		}
+		cmd.IO.Outf("Wrote TLS certificate to %s\n", cmd.CertOut)
+	}
+
+	cmd.IO.Outf("Mock APNs server listening on %s\n", server.Addr)
+	cmd.IO.Outf("Send with: apnstool send ... --endpoint %s --ca-file <cert>\n", server.Addr)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+
+	cmd.IO.Out("Shutting down\n")
+
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package profile
+
+import (
+	"strconv"
+
+	"github.com/brannon/apnstool/cmd/auth"
+	"github.com/brannon/apnstool/cmd/send"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/brannon/apnstool/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	ConfigFileFlag    = "config"
+	ConfigFileDefault = ""
+	ConfigFileDesc    = "path to config file (default is $HOME/.config/apnstool/config.yaml)"
+
+	ProfileFlag    = "profile"
+	ProfileDefault = ""
+	ProfileDesc    = "name of the config profile to use (default is the config's default profile)"
+)
+
+// ConfigOptions selects the config file and profile used by all commands.
+type ConfigOptions struct {
+	ConfigFile string
+	Profile    string
+}
+
+func BindConfigFlags(flags *pflag.FlagSet, options *ConfigOptions) {
+	flags.StringVar(&options.ConfigFile, ConfigFileFlag, ConfigFileDefault, ConfigFileDesc)
+	flags.StringVar(&options.Profile, ProfileFlag, ProfileDefault, ProfileDesc)
+	cmdio.BindEnv(flags, ConfigFileFlag, ProfileFlag)
+}
+
+func (options *ConfigOptions) path() (string, error) {
+	if options.ConfigFile != "" {
+		return options.ConfigFile, nil
+	}
+	return config.DefaultPath()
+}
+
+func (options *ConfigOptions) Load() (*config.Config, error) {
+	path, err := options.path()
+	if err != nil {
+		return nil, err
+	}
+	return config.Load(path)
+}
+
+func (options *ConfigOptions) Save(cfg *config.Config) error {
+	path, err := options.path()
+	if err != nil {
+		return err
+	}
+	return cfg.Save(path)
+}
+
+// ApplyProfile fills flags that were not set on the command line or through
+// the environment with values from the selected profile. The profile's
+// credentials are skipped entirely when the flags already give a complete
+// credential, so that a profile never overrides the authentication method
+// chosen by the user; otherwise they fill in whatever is missing.
+func ApplyProfile(flags *pflag.FlagSet, options *ConfigOptions) error {
+	cfg, err := options.Load()
+	if err != nil {
+		return err
+	}
+
+	p, err := cfg.Profile(options.Profile)
+	if err != nil || p == nil {
+		return err
+	}
+
+	values := map[string]string{
+		send.AppIdFlag: p.AppId,
+	}
+
+	if p.Environment != "" {
+		values[send.SandboxFlag] = strconv.FormatBool(p.Environment == config.EnvironmentSandbox)
+	}
+
+	if len(p.DeviceTokens) > 0 {
+		values[send.DeviceTokenFlag] = p.DeviceTokens[0]
+	}
+
+	if !hasCredential(flags) {
+		values[auth.KeyFileFlag] = p.TokenAuth.KeyFile
+		values[auth.KeyIdFlag] = p.TokenAuth.KeyId
+		values[auth.TeamIdFlag] = p.TokenAuth.TeamId
+		values[auth.CertificateFileFlag] = p.CertificateAuth.CertificateFile
+		values[auth.CertificateKeyFileFlag] = p.CertificateAuth.CertificateKeyFile
+		values[auth.CertificatePasswordFlag] = p.CertificateAuth.CertificatePassword
+
+		if p.TokenAuth.ExpiresAfter != 0 {
+			values[auth.ExpiresAfterFlag] = p.TokenAuth.ExpiresAfter.String()
+		}
+	}
+
+	for name, value := range values {
+		flag := flags.Lookup(name)
+		if flag == nil || flag.Changed || value == "" {
+			continue
+		}
+
+		if err := flags.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hasCredential reports whether the flags, from the command line or the
+// environment, already give a complete token or certificate credential.
+func hasCredential(flags *pflag.FlagSet) bool {
+	return allChanged(flags, auth.KeyFileFlag, auth.KeyIdFlag, auth.TeamIdFlag) ||
+		allChanged(flags, auth.CertificateFileFlag)
+}
+
+func allChanged(flags *pflag.FlagSet, names ...string) bool {
+	for _, name := range names {
+		if !flags.Changed(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func GetCommand(options *ConfigOptions) *cobra.Command {
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage saved credential profiles",
+		Args:  cobra.NoArgs,
+		// Profile commands edit profiles rather than use them, so only
+		// the environment is applied, not the selected profile.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdio.ApplyEnv(cmd.Flags()); err != nil {
+				return err
+			}
+			return cmdio.ValidateOutputFlag()
+		},
+	}
+
+	profileCmd.AddCommand(NewProfileAddCommand(options))
+	profileCmd.AddCommand(NewProfileListCommand(options))
+	profileCmd.AddCommand(NewProfileRemoveCommand(options))
+	profileCmd.AddCommand(NewProfileShowCommand(options))
+
+	return profileCmd
+}
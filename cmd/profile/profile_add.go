@@ -0,0 +1,146 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package profile
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/brannon/apnstool/cmd/auth"
+	"github.com/brannon/apnstool/cmd/send"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/brannon/apnstool/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DefaultFlag    = "default"
+	DefaultDefault = false
+	DefaultDesc    = "make this the default profile"
+
+	DeviceTokensDesc = "default APNs device token (repeatable; the first is used by send commands)"
+
+	ForceFlag    = "force"
+	ForceDefault = false
+	ForceDesc    = "replace the profile if it already exists"
+)
+
+type ProfileAddCmd struct {
+	AppId           string
+	CertificateAuth auth.CertificateAuth
+	Default         bool
+	DeviceTokens    []string
+	Force           bool
+	Name            string
+	Sandbox         bool
+	TokenAuth       auth.TokenAuth
+
+	options *ConfigOptions
+	io      cmdio.CmdIO
+}
+
+func NewProfileAddCommand(options *ConfigOptions) *cobra.Command {
+	cmd := &ProfileAddCmd{options: options}
+
+	cobraCmd := &cobra.Command{
+		Use:   "add NAME",
+		Short: "Save credentials and defaults as a named profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.io = cmdio.NewCmdIO(c.OutOrStdout())
+			cmd.Name = args[0]
+
+			if !c.Flags().Changed(auth.ExpiresAfterFlag) {
+				cmd.TokenAuth.ExpiresAfter = 0
+			}
+
+			return cmd.Run()
+		},
+	}
+
+	flags := cobraCmd.Flags()
+	auth.BindTokenAuthFlags(flags, &cmd.TokenAuth)
+	auth.BindCertificateAuthFlags(flags, &cmd.CertificateAuth)
+	flags.StringVar(&cmd.AppId, send.AppIdFlag, send.AppIdDefault, send.AppIdDesc)
+	flags.BoolVar(&cmd.Default, DefaultFlag, DefaultDefault, DefaultDesc)
+	flags.StringArrayVar(&cmd.DeviceTokens, send.DeviceTokenFlag, nil, DeviceTokensDesc)
+	flags.BoolVar(&cmd.Force, ForceFlag, ForceDefault, ForceDesc)
+	flags.BoolVar(&cmd.Sandbox, send.SandboxFlag, send.SandboxDefault, send.SandboxDesc)
+
+	return cobraCmd
+}
+
+func (cmd *ProfileAddCmd) Run() error {
+	cfg, err := cmd.options.Load()
+	if err != nil {
+		return err
+	}
+
+	if _, exists := cfg.Profiles[cmd.Name]; exists && !cmd.Force {
+		return fmt.Errorf("profile %q already exists (use --%s to replace it)", cmd.Name, ForceFlag)
+	}
+
+	// Store absolute paths so the profile works from any directory.
+	if err := makeAbs(&cmd.TokenAuth.KeyFile); err != nil {
+		return err
+	}
+	if err := makeAbs(&cmd.CertificateAuth.CertificateFile); err != nil {
+		return err
+	}
+	if err := makeAbs(&cmd.CertificateAuth.CertificateKeyFile); err != nil {
+		return err
+	}
+
+	environment := config.EnvironmentProduction
+	if cmd.Sandbox {
+		environment = config.EnvironmentSandbox
+	}
+
+	cfg.SetProfile(cmd.Name, &config.Profile{
+		AppId:           cmd.AppId,
+		Environment:     environment,
+		DeviceTokens:    cmd.DeviceTokens,
+		TokenAuth:       cmd.TokenAuth,
+		CertificateAuth: cmd.CertificateAuth,
+	})
+
+	if cmd.Default || len(cfg.Profiles) == 1 {
+		cfg.DefaultProfile = cmd.Name
+	}
+
+	if err := cmd.options.Save(cfg); err != nil {
+		return err
+	}
+
+	return cmd.io.Output(&ProfileAddOutput{
+		Name:    cmd.Name,
+		Default: cfg.DefaultProfile == cmd.Name,
+	})
+}
+
+// ProfileAddOutput describes the profile that was saved.
+type ProfileAddOutput struct {
+	Name    string `json:"name" yaml:"name"`
+	Default bool   `json:"default" yaml:"default"`
+}
+
+func (output *ProfileAddOutput) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Profile %q saved\n", output.Name)
+}
+
+func makeAbs(path *string) error {
+	if *path == "" {
+		return nil
+	}
+
+	abs, err := filepath.Abs(*path)
+	if err != nil {
+		return err
+	}
+
+	*path = abs
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package profile
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/spf13/cobra"
+)
+
+type ProfileListCmd struct {
+	options *ConfigOptions
+	io      cmdio.CmdIO
+}
+
+func NewProfileListCommand(options *ConfigOptions) *cobra.Command {
+	cmd := &ProfileListCmd{options: options}
+
+	cobraCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved profiles",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.io = cmdio.NewCmdIO(c.OutOrStdout())
+
+			return cmd.Run()
+		},
+	}
+
+	return cobraCmd
+}
+
+func (cmd *ProfileListCmd) Run() error {
+	cfg, err := cmd.options.Load()
+	if err != nil {
+		return err
+	}
+
+	return cmd.io.Output(&ProfileListOutput{
+		DefaultProfile: cfg.DefaultProfile,
+		Profiles:       append([]string{}, cfg.ProfileNames()...),
+	})
+}
+
+// ProfileListOutput lists the saved profiles and which one is the default.
+type ProfileListOutput struct {
+	DefaultProfile string   `json:"default-profile,omitempty" yaml:"default-profile,omitempty"`
+	Profiles       []string `json:"profiles" yaml:"profiles"`
+}
+
+func (output *ProfileListOutput) WriteText(w io.Writer) {
+	for _, name := range output.Profiles {
+		if name == output.DefaultProfile {
+			fmt.Fprintf(w, "%s (default)\n", name)
+		} else {
+			fmt.Fprintf(w, "%s\n", name)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package profile
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/spf13/cobra"
+)
+
+type ProfileRemoveCmd struct {
+	Name string
+
+	options *ConfigOptions
+	io      cmdio.CmdIO
+}
+
+func NewProfileRemoveCommand(options *ConfigOptions) *cobra.Command {
+	cmd := &ProfileRemoveCmd{options: options}
+
+	cobraCmd := &cobra.Command{
+		Use:   "remove NAME",
+		Short: "Remove a saved profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.io = cmdio.NewCmdIO(c.OutOrStdout())
+			cmd.Name = args[0]
+
+			return cmd.Run()
+		},
+	}
+
+	return cobraCmd
+}
+
+func (cmd *ProfileRemoveCmd) Run() error {
+	cfg, err := cmd.options.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.RemoveProfile(cmd.Name); err != nil {
+		return err
+	}
+
+	if err := cmd.options.Save(cfg); err != nil {
+		return err
+	}
+
+	return cmd.io.Output(&ProfileRemoveOutput{Name: cmd.Name})
+}
+
+// ProfileRemoveOutput names the profile that was removed.
+type ProfileRemoveOutput struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+func (output *ProfileRemoveOutput) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Profile %q removed\n", output.Name)
+}
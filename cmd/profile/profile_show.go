@@ -0,0 +1,91 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package profile
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/brannon/apnstool/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ShowSecretsFlag    = "show-secrets"
+	ShowSecretsDefault = false
+	ShowSecretsDesc    = "show the certificate password instead of redacting it"
+
+	redacted = "********"
+)
+
+type ProfileShowCmd struct {
+	Name        string
+	ShowSecrets bool
+
+	options *ConfigOptions
+	io      cmdio.CmdIO
+}
+
+func NewProfileShowCommand(options *ConfigOptions) *cobra.Command {
+	cmd := &ProfileShowCmd{options: options}
+
+	cobraCmd := &cobra.Command{
+		Use:   "show NAME",
+		Short: "Show a saved profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.io = cmdio.NewCmdIO(c.OutOrStdout())
+			cmd.Name = args[0]
+
+			return cmd.Run()
+		},
+	}
+
+	cobraCmd.Flags().BoolVar(&cmd.ShowSecrets, ShowSecretsFlag, ShowSecretsDefault, ShowSecretsDesc)
+
+	return cobraCmd
+}
+
+func (cmd *ProfileShowCmd) Run() error {
+	cfg, err := cmd.options.Load()
+	if err != nil {
+		return err
+	}
+
+	p, err := cfg.Profile(cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	shown := *p
+	if shown.CertificateAuth.CertificatePassword != "" && !cmd.ShowSecrets {
+		shown.CertificateAuth.CertificatePassword = redacted
+	}
+
+	return cmd.io.Output(&ProfileShowOutput{
+		Name:    cmd.Name,
+		Profile: shown,
+	})
+}
+
+// ProfileShowOutput is a saved profile. Its text form is the profile as it
+// appears in the config file.
+type ProfileShowOutput struct {
+	Name           string `json:"name" yaml:"name"`
+	config.Profile `yaml:",inline"`
+}
+
+func (output *ProfileShowOutput) WriteText(w io.Writer) {
+	data, err := yaml.Marshal(&output.Profile)
+	if err != nil {
+		fmt.Fprintf(w, "# %s: %s\n", output.Name, err)
+		return
+	}
+
+	fmt.Fprintf(w, "# %s\n", output.Name)
+	fmt.Fprint(w, string(data))
+}
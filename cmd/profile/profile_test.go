@@ -0,0 +1,134 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brannon/apnstool/cmd/auth"
+	"github.com/brannon/apnstool/cmd/send"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/brannon/apnstool/config"
+	"github.com/spf13/pflag"
+)
+
+func TestApplyProfile(t *testing.T) {
+	profile := &config.Profile{
+		AppId:        "com.example.app",
+		Environment:  config.EnvironmentSandbox,
+		DeviceTokens: []string{"profile-device"},
+		TokenAuth: auth.TokenAuth{
+			KeyFile: "profile.p8",
+			KeyId:   "PROFILEKEY",
+			TeamId:  "PROFILETEAM",
+		},
+	}
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		token auth.TokenAuth
+		cert  auth.CertificateAuth
+		appId string
+	}{
+		{
+			name:  "profile only",
+			token: profile.TokenAuth,
+			appId: "com.example.app",
+		},
+		{
+			name:  "flag overrides profile",
+			args:  []string{"--app-id", "com.example.flag"},
+			token: profile.TokenAuth,
+			appId: "com.example.flag",
+		},
+		{
+			name:  "flag overrides env",
+			args:  []string{"--app-id", "com.example.flag"},
+			env:   map[string]string{"APNSTOOL_APP_ID": "com.example.env"},
+			token: profile.TokenAuth,
+			appId: "com.example.flag",
+		},
+		{
+			name:  "env sets one credential field, profile supplies the rest",
+			env:   map[string]string{"APNSTOOL_KEY_ID": "ENVKEY"},
+			token: auth.TokenAuth{KeyFile: "profile.p8", KeyId: "ENVKEY", TeamId: "PROFILETEAM"},
+			appId: "com.example.app",
+		},
+		{
+			name: "complete token credential skips profile credentials",
+			args: []string{"--key-file", "flag.p8", "--key-id", "FLAGKEY"},
+			env:  map[string]string{"APNSTOOL_TEAM_ID": "ENVTEAM"},
+			token: auth.TokenAuth{
+				KeyFile: "flag.p8",
+				KeyId:   "FLAGKEY",
+				TeamId:  "ENVTEAM",
+			},
+			appId: "com.example.app",
+		},
+		{
+			name:  "certificate skips profile token",
+			args:  []string{"--cert-file", "flag.p12"},
+			cert:  auth.CertificateAuth{CertificateFile: "flag.p12"},
+			appId: "com.example.app",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "apnstool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{}
+	cfg.SetProfile("test", profile)
+	options := &ConfigOptions{ConfigFile: filepath.Join(dir, "config.yaml"), Profile: "test"}
+	if err := options.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+
+			var cmd send.SendCmd
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			send.BindSendCommonFlags(flags, &cmd)
+			send.BindDeviceTokenFlag(flags, &cmd)
+
+			if err := flags.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := cmdio.ApplyEnv(flags); err != nil {
+				t.Fatal(err)
+			}
+			if err := ApplyProfile(flags, options); err != nil {
+				t.Fatal(err)
+			}
+
+			test.token.ExpiresAfter = auth.ExpiresAfterDefault
+			if !reflect.DeepEqual(cmd.TokenAuth, test.token) {
+				t.Errorf("got token auth %+v, want %+v", cmd.TokenAuth, test.token)
+			}
+			if !reflect.DeepEqual(cmd.CertificateAuth, test.cert) {
+				t.Errorf("got certificate auth %+v, want %+v", cmd.CertificateAuth, test.cert)
+			}
+			if cmd.AppId != test.appId {
+				t.Errorf("got app ID %q, want %q", cmd.AppId, test.appId)
+			}
+			if !cmd.Sandbox || cmd.DeviceToken != "profile-device" {
+				t.Errorf("got sandbox %v and device token %q from profile", cmd.Sandbox, cmd.DeviceToken)
+			}
+		})
+	}
+}
@@ -5,11 +5,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/brannon/apnstool/cmd/auth"
+	"github.com/brannon/apnstool/cmd/diagnose"
+	"github.com/brannon/apnstool/cmd/mockserver"
+	"github.com/brannon/apnstool/cmd/profile"
 	"github.com/brannon/apnstool/cmd/send"
+	"github.com/brannon/apnstool/cmd/validate"
+	"github.com/brannon/apnstool/cmdio"
 	"github.com/spf13/cobra"
 )
 
@@ -18,16 +24,45 @@ var rootCmd = &cobra.Command{
 	Short:        "APNSTool is a command-line tool for interacting with APNs",
 	Args:         cobra.NoArgs,
 	SilenceUsage: true,
+	// Flags not given on the command line are taken from the environment,
+	// then from the selected profile, before falling back to their defaults.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := cmdio.ApplyEnv(cmd.Flags()); err != nil {
+			return err
+		}
+		if err := cmdio.ValidateOutputFlag(); err != nil {
+			return err
+		}
+		return profile.ApplyProfile(cmd.Flags(), &configOptions)
+	},
 }
 
+var configOptions profile.ConfigOptions
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		// Cobra has already written the error to stderr. Repeat it on stdout
+		// only for text output, so that JSON and YAML output stay parseable.
+		if cmdio.SelectedOutputFormat() == cmdio.OutputText {
+			fmt.Println(err)
+		}
+
+		var exitErr *cmdio.ExitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+		os.Exit(cmdio.ExitError)
 	}
 }
 
 func init() {
+	profile.BindConfigFlags(rootCmd.PersistentFlags(), &configOptions)
+	cmdio.BindOutputFlag(rootCmd.PersistentFlags())
+
 	rootCmd.AddCommand(auth.GetCommand())
+	rootCmd.AddCommand(diagnose.GetCommand())
+	rootCmd.AddCommand(mockserver.GetCommand())
+	rootCmd.AddCommand(profile.GetCommand(&configOptions))
 	rootCmd.AddCommand(send.GetCommand())
+	rootCmd.AddCommand(validate.GetCommand())
 }
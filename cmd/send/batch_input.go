@@ -0,0 +1,296 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package send
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/brannon/apnstool/config"
+)
+
+// Input formats for send batch.
+const (
+	BatchFormatCSV   = "csv"
+	BatchFormatJSONL = "jsonl"
+)
+
+// Column names in a send batch CSV file. Columns named after an apns- header
+// set that header; any other column sets the payload field at its dotted
+// path (e.g. aps.alert.title).
+const (
+	deviceTokenColumn = "device_token"
+	environmentColumn = "environment"
+	payloadColumn     = "payload"
+)
+
+// batchRow is a single device in a send batch input file.
+type batchRow struct {
+	Row         int                    `json:"-"`
+	DeviceToken string                 `json:"device_token" yaml:"device_token"`
+	Environment string                 `json:"environment" yaml:"environment"`
+	Headers     map[string]string      `json:"headers" yaml:"headers"`
+	Payload     map[string]interface{} `json:"payload" yaml:"payload"`
+}
+
+// batchResult is the outcome of sending to a single row, written as one line
+// of the results file.
+type batchResult struct {
+	Row         int    `json:"row" yaml:"row"`
+	DeviceToken string `json:"device_token" yaml:"device_token"`
+	Environment string `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Status      int    `json:"status" yaml:"status"`
+	Reason      string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	ApnsId      string `json:"apns_id,omitempty" yaml:"apns_id,omitempty"`
+	Error       string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func (result *batchResult) succeeded() bool {
+	return result.Status == 200
+}
+
+// readBatchRows reads the rows of a JSONL or CSV input file. The format is
+// taken from the file extension if not given.
+func readBatchRows(path string, format string, stdin io.Reader) ([]*batchRow, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = BatchFormatCSV
+		default:
+			format = BatchFormatJSONL
+		}
+	}
+
+	var reader io.Reader = stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	var rows []*batchRow
+	var err error
+
+	switch format {
+	case BatchFormatCSV:
+		rows, err = readCSVRows(reader)
+	case BatchFormatJSONL:
+		rows, err = readJSONLRows(reader)
+	default:
+		return nil, fmt.Errorf("invalid --%s %q (must be %s or %s)", InputFormatFlag, format, BatchFormatJSONL, BatchFormatCSV)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if row.DeviceToken == "" {
+			return nil, fmt.Errorf("row %d: missing %s", row.Row, deviceTokenColumn)
+		}
+
+		switch row.Environment {
+		case "", config.EnvironmentProduction, config.EnvironmentSandbox:
+		default:
+			return nil, fmt.Errorf("row %d: invalid environment %q (must be %q or %q)",
+				row.Row, row.Environment, config.EnvironmentProduction, config.EnvironmentSandbox)
+		}
+	}
+
+	return rows, nil
+}
+
+func readJSONLRows(reader io.Reader) ([]*batchRow, error) {
+	var rows []*batchRow
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		row := &batchRow{Row: len(rows) + 1}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(row); err != nil {
+			return nil, fmt.Errorf("row %d: %s", row.Row, err)
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+func readCSVRows(reader io.Reader) ([]*batchRow, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	columns, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+
+	var rows []*batchRow
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		row := &batchRow{Row: len(rows) + 1}
+
+		for i, value := range record {
+			if value == "" {
+				continue
+			}
+
+			column := columns[i]
+			switch {
+			case column == deviceTokenColumn:
+				row.DeviceToken = value
+			case column == environmentColumn:
+				row.Environment = value
+			case column == payloadColumn:
+				payload, err := parseDataString(value)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: invalid %s: %s", row.Row, payloadColumn, err)
+				}
+				row.Payload = mergeContent(row.Payload, payload)
+			case strings.HasPrefix(strings.ToLower(column), "apns-"):
+				if row.Headers == nil {
+					row.Headers = make(map[string]string)
+				}
+				row.Headers[strings.ToLower(column)] = value
+			default:
+				if row.Payload == nil {
+					row.Payload = make(map[string]interface{})
+				}
+				setPath(row.Payload, strings.Split(column, "."), parseCSVValue(value))
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseCSVValue returns the JSON value a CSV cell holds, such as a number
+// for a badge count, or the cell text if it is not valid JSON.
+func parseCSVValue(value string) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		return parsed
+	}
+	return value
+}
+
+// setPath sets the value at a dotted path in the payload, creating nested
+// objects as needed.
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// mergeContent merges src into dst, combining nested objects rather than
+// replacing them, and returns dst.
+func mergeContent(dst map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+
+	for key, value := range src {
+		srcMap, srcOk := value.(map[string]interface{})
+		dstMap, dstOk := dst[key].(map[string]interface{})
+		if srcOk && dstOk {
+			dst[key] = mergeContent(dstMap, srcMap)
+		} else {
+			dst[key] = value
+		}
+	}
+
+	return dst
+}
+
+// readBatchResults reads a results file written by a previous send batch,
+// keyed by row.
+func readBatchResults(path string) (map[int]*batchResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	results := make(map[int]*batchResult)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		result := &batchResult{}
+		if err := json.Unmarshal(line, result); err != nil {
+			return nil, fmt.Errorf("invalid results file %s: %s", path, err)
+		}
+		results[result.Row] = result
+	}
+
+	return results, scanner.Err()
+}
+
+// writeBatchResults writes the results, ordered by row, as JSONL.
+func writeBatchResults(path string, results []*batchResult) error {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Row < results[j].Row
+	})
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
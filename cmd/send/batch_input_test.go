@@ -0,0 +1,334 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package send
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONLRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []*batchRow
+		err   string
+	}{
+		{
+			name:  "token only",
+			input: `{"device_token":"aa"}`,
+			want:  []*batchRow{{Row: 1, DeviceToken: "aa"}},
+		},
+		{
+			name: "all fields and blank lines",
+			input: `{"device_token":"aa","environment":"sandbox","headers":{"apns-priority":"5"},"payload":{"aps":{"badge":1}}}
+
+{"device_token":"bb"}
+`,
+			want: []*batchRow{
+				{
+					Row:         1,
+					DeviceToken: "aa",
+					Environment: "sandbox",
+					Headers:     map[string]string{"apns-priority": "5"},
+					Payload:     map[string]interface{}{"aps": map[string]interface{}{"badge": float64(1)}},
+				},
+				{Row: 2, DeviceToken: "bb"},
+			},
+		},
+		{
+			name:  "unknown field",
+			input: `{"device_token":"aa","token":"bb"}`,
+			err:   `row 1: json: unknown field "token"`,
+		},
+		{
+			name:  "invalid JSON",
+			input: "{\"device_token\":\"aa\"}\n{",
+			err:   "row 2: unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := readJSONLRows(strings.NewReader(test.input))
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, test.want) {
+				t.Errorf("got %+v, want %+v", rows, test.want)
+			}
+		})
+	}
+}
+
+func TestReadCSVRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []*batchRow
+		err   string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "header only",
+			input: "device_token\n",
+		},
+		{
+			name:  "columns",
+			input: "device_token, environment, APNs-Priority, aps.alert.title, aps.badge\naa, sandbox, 5, Hi Ann, 3\n",
+			want: []*batchRow{{
+				Row:         1,
+				DeviceToken: "aa",
+				Environment: "sandbox",
+				Headers:     map[string]string{"apns-priority": "5"},
+				Payload: map[string]interface{}{"aps": map[string]interface{}{
+					"alert": map[string]interface{}{"title": "Hi Ann"},
+					"badge": float64(3),
+				}},
+			}},
+		},
+		{
+			name:  "payload column merged with paths",
+			input: "device_token,payload,aps.alert.body\naa,\"{\"\"aps\"\":{\"\"alert\"\":{\"\"title\"\":\"\"Hi\"\"}}}\",Hello\n",
+			want: []*batchRow{{
+				Row:         1,
+				DeviceToken: "aa",
+				Payload: map[string]interface{}{"aps": map[string]interface{}{
+					"alert": map[string]interface{}{"title": "Hi", "body": "Hello"},
+				}},
+			}},
+		},
+		{
+			name:  "empty cells skipped",
+			input: "device_token,aps.badge\naa,\nbb,2\n",
+			want: []*batchRow{
+				{Row: 1, DeviceToken: "aa"},
+				{Row: 2, DeviceToken: "bb", Payload: map[string]interface{}{"aps": map[string]interface{}{"badge": float64(2)}}},
+			},
+		},
+		{
+			name:  "invalid payload",
+			input: "device_token,payload\naa,{\n",
+			err:   "row 1: invalid payload: unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := readCSVRows(strings.NewReader(test.input))
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, test.want) {
+				t.Errorf("got %+v, want %+v", rows, test.want)
+			}
+		})
+	}
+}
+
+func TestReadBatchRowsValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		format string
+		err    string
+	}{
+		{name: "missing token", input: `{"environment":"sandbox"}`, err: "row 1: missing device_token"},
+		{name: "invalid environment", input: `{"device_token":"aa","environment":"dev"}`, err: `row 1: invalid environment "dev" (must be "production" or "sandbox")`},
+		{name: "invalid format", input: `{"device_token":"aa"}`, format: "xml", err: `invalid --input-format "xml" (must be jsonl or csv)`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := readBatchRows("-", test.format, strings.NewReader(test.input))
+			if err == nil || err.Error() != test.err {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		start map[string]interface{}
+		path  string
+		want  map[string]interface{}
+	}{
+		{
+			name:  "top level",
+			start: map[string]interface{}{},
+			path:  "key",
+			want:  map[string]interface{}{"key": "value"},
+		},
+		{
+			name:  "creates objects",
+			start: map[string]interface{}{},
+			path:  "aps.alert.title",
+			want:  map[string]interface{}{"aps": map[string]interface{}{"alert": map[string]interface{}{"title": "value"}}},
+		},
+		{
+			name:  "keeps siblings",
+			start: map[string]interface{}{"aps": map[string]interface{}{"badge": 1}},
+			path:  "aps.sound",
+			want:  map[string]interface{}{"aps": map[string]interface{}{"badge": 1, "sound": "value"}},
+		},
+		{
+			name:  "replaces non-object",
+			start: map[string]interface{}{"aps": map[string]interface{}{"alert": "hi"}},
+			path:  "aps.alert.title",
+			want:  map[string]interface{}{"aps": map[string]interface{}{"alert": map[string]interface{}{"title": "value"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setPath(test.start, strings.Split(test.path, "."), "value")
+			if !reflect.DeepEqual(test.start, test.want) {
+				t.Errorf("got %v, want %v", test.start, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeContent(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  map[string]interface{}
+		src  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "nil dst",
+			src:  map[string]interface{}{"a": 1},
+			want: map[string]interface{}{"a": 1},
+		},
+		{
+			name: "nested objects combined",
+			dst:  map[string]interface{}{"aps": map[string]interface{}{"alert": map[string]interface{}{"title": "Hi"}, "badge": 1}},
+			src:  map[string]interface{}{"aps": map[string]interface{}{"alert": map[string]interface{}{"body": "Hello"}}},
+			want: map[string]interface{}{"aps": map[string]interface{}{"alert": map[string]interface{}{"title": "Hi", "body": "Hello"}, "badge": 1}},
+		},
+		{
+			name: "src replaces non-object",
+			dst:  map[string]interface{}{"aps": map[string]interface{}{"alert": "Hi"}},
+			src:  map[string]interface{}{"aps": map[string]interface{}{"alert": map[string]interface{}{"body": "Hello"}}},
+			want: map[string]interface{}{"aps": map[string]interface{}{"alert": map[string]interface{}{"body": "Hello"}}},
+		},
+		{
+			name: "src value wins",
+			dst:  map[string]interface{}{"a": 1, "b": 2},
+			src:  map[string]interface{}{"a": 3},
+			want: map[string]interface{}{"a": 3, "b": 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := mergeContent(test.dst, test.src); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBatchResultsRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apnstool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "results.jsonl")
+
+	results := []*batchResult{
+		{Row: 3, DeviceToken: "cc", Error: "connection refused"},
+		{Row: 1, DeviceToken: "aa", Environment: "sandbox", Status: 200, ApnsId: "id-1"},
+		{Row: 2, DeviceToken: "bb", Status: 410, Reason: "Unregistered"},
+	}
+
+	if err := writeBatchResults(path, results); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], `{"row":1,`) || !strings.HasPrefix(lines[2], `{"row":3,`) {
+		t.Errorf("results not written in row order:\n%s", data)
+	}
+
+	previous, err := readBatchResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range results {
+		if got := previous[result.Row]; !reflect.DeepEqual(got, result) {
+			t.Errorf("row %d: got %+v, want %+v", result.Row, got, result)
+		}
+	}
+
+	for row, want := range map[int]bool{1: true, 2: false, 3: false} {
+		if got := previous[row].succeeded(); got != want {
+			t.Errorf("row %d: got succeeded %v, want %v", row, got, want)
+		}
+	}
+}
+
+func TestRowErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []*batchResult
+		err     string
+	}{
+		{name: "none"},
+		{name: "resumed successes", results: []*batchResult{{Row: 1, Status: 200}}},
+		{
+			name: "sorted by row",
+			results: []*batchResult{
+				{Row: 3, Error: "unknown APNs header \"apns-bogus\""},
+				{Row: 1, Status: 200},
+				{Row: 2, Error: "failed validation: payload is empty"},
+			},
+			err: `could not build row 2: failed validation: payload is empty; row 3: unknown APNs header "apns-bogus"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := rowErrors(test.results)
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("got error %v, want none", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.err {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
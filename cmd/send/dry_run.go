@@ -0,0 +1,140 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package send
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/brannon/apnstool/apns"
+)
+
+const redacted = "<redacted>"
+
+// DryRunOutput is the request a send command would make with --dry-run.
+type DryRunOutput struct {
+	Method    string                 `json:"method" yaml:"method"`
+	URL       string                 `json:"url" yaml:"url"`
+	Headers   map[string]string      `json:"headers" yaml:"headers"`
+	Payload   interface{}            `json:"payload" yaml:"payload"`
+	JWTHeader map[string]interface{} `json:"jwt_header,omitempty" yaml:"jwt_header,omitempty"`
+	JWTClaims map[string]interface{} `json:"jwt_claims,omitempty" yaml:"jwt_claims,omitempty"`
+
+	content []byte
+}
+
+// DryRunOutputs are the requests send batch would make.
+type DryRunOutputs []*DryRunOutput
+
+func (outputs DryRunOutputs) WriteText(w io.Writer) {
+	for i, output := range outputs {
+		if i > 0 {
+			fmt.Fprint(w, "\n")
+		}
+		output.WriteText(w)
+	}
+}
+
+// printRequest prints the request the client would send for the
+// notification.
+func (cmd *SendCmd) printRequest(client apns.Client, deviceToken string, headers apns.Headers, content []byte) error {
+	output, err := cmd.newDryRunOutput(client, deviceToken, headers, content)
+	if err != nil {
+		return err
+	}
+
+	return cmd.IO.Output(output)
+}
+
+// newDryRunOutput describes the request the client would send for the
+// notification. The provider token is redacted unless --show-secrets is set,
+// but its header and claims are always shown.
+func (cmd *SendCmd) newDryRunOutput(client apns.Client, deviceToken string, headers apns.Headers, content []byte) (*DryRunOutput, error) {
+	req, err := client.NewRequest(deviceToken, headers, content)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &DryRunOutput{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: make(map[string]string),
+		Payload: string(content),
+		content: content,
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(content, &payload); err == nil {
+		output.Payload = payload
+	}
+
+	for name := range req.Header {
+		output.Headers[strings.ToLower(name)] = req.Header.Get(name)
+	}
+
+	if authorization := req.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		bearerToken := strings.TrimPrefix(authorization, "Bearer ")
+		if !cmd.ShowSecrets {
+			output.Headers["authorization"] = "Bearer " + redacted
+		}
+
+		output.JWTHeader, output.JWTClaims, err = apns.DecodeJWT(bearerToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return output, nil
+}
+
+func (output *DryRunOutput) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s %s\n", output.Method, output.URL)
+
+	names := make([]string, 0, len(output.Headers))
+	for name := range output.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s\n", name, output.Headers[name])
+	}
+
+	fmt.Fprintf(w, "\n%s\n\n", output.content)
+
+	if output.JWTHeader != nil {
+		fmt.Fprintf(w, "JWT header: %s\n", marshalJSON(output.JWTHeader))
+		fmt.Fprintf(w, "JWT claims: %s\n", marshalJSON(output.JWTClaims))
+	}
+
+	fmt.Fprint(w, "Dry run: notification not sent\n")
+}
+
+// printCertificate prints the subject of the client certificate used for
+// the request.
+func (cmd *SendCmd) printCertificate(cert tls.Certificate) {
+	if cert.Leaf == nil {
+		return
+	}
+
+	info, err := apns.InspectCertificate(cert.Leaf)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(cmd.IO.Log(), "Client certificate: %s (expires %s, chain of %d)\n",
+		info.Subject, info.NotAfter.Format("2006-01-02"), len(cert.Certificate))
+}
+
+func marshalJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
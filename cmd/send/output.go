@@ -0,0 +1,76 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package send
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/brannon/apnstool/apns"
+)
+
+// SendOutput is the APNs response to a single notification.
+type SendOutput struct {
+	DeviceToken       string `json:"device_token" yaml:"device_token"`
+	Endpoint          string `json:"endpoint" yaml:"endpoint"`
+	PushType          string `json:"push_type,omitempty" yaml:"push_type,omitempty"`
+	Success           bool   `json:"success" yaml:"success"`
+	StatusCode        int    `json:"status_code" yaml:"status_code"`
+	Reason            string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	ReasonDescription string `json:"reason_description,omitempty" yaml:"reason_description,omitempty"`
+	Timestamp         string `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+	ApnsId            string `json:"apns_id" yaml:"apns_id"`
+	ApnsUniqueId      string `json:"apns_unique_id,omitempty" yaml:"apns_unique_id,omitempty"`
+	LatencyMs         int64  `json:"latency_ms" yaml:"latency_ms"`
+}
+
+func newSendOutput(result *apns.SendResult, endpoint string, pushType string, latency time.Duration) *SendOutput {
+	if endpoint == "" {
+		endpoint = apns.ProductionEndpoint
+	}
+
+	output := &SendOutput{
+		DeviceToken:  result.DeviceToken,
+		Endpoint:     endpoint,
+		PushType:     pushType,
+		Success:      result.Success(),
+		StatusCode:   result.StatusCode,
+		ApnsId:       result.Id(),
+		ApnsUniqueId: result.UniqueId(),
+		LatencyMs:    int64(latency / time.Millisecond),
+	}
+
+	if apnsErr := result.APNsError(); apnsErr != nil {
+		output.Reason = string(apnsErr.Reason)
+		output.ReasonDescription = apnsErr.Reason.Description()
+		if !apnsErr.Timestamp.IsZero() {
+			output.Timestamp = apnsErr.Timestamp.Format(time.RFC3339)
+		}
+	}
+
+	return output
+}
+
+func (output *SendOutput) WriteText(w io.Writer) {
+	if output.Success {
+		fmt.Fprint(w, "Notification sent successfully\n")
+		fmt.Fprintf(w, "APNS-ID: %s\n", output.ApnsId)
+		return
+	}
+
+	fmt.Fprint(w, "Notification failed\n")
+	fmt.Fprintf(w, "Status: %d\n", output.StatusCode)
+	if output.Reason != "" {
+		fmt.Fprintf(w, "Reason: %s\n", output.Reason)
+		if output.ReasonDescription != "" {
+			fmt.Fprintf(w, "  %s\n", output.ReasonDescription)
+		}
+	}
+	if output.Timestamp != "" {
+		fmt.Fprintf(w, "Timestamp: %s\n", output.Timestamp)
+	}
+	fmt.Fprintf(w, "APNS-ID: %s\n", output.ApnsId)
+}
@@ -6,7 +6,17 @@ package send
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/brannon/apnstool/apns"
@@ -18,23 +28,79 @@ import (
 )
 
 const (
+	ApnsIdFlag    = "apns-id"
+	ApnsIdDefault = ""
+	ApnsIdDesc    = "value for 'apns-id' header (a UUID that APNs returns in its response)"
+
 	AppIdFlag    = "app-id"
 	AppIdDefault = ""
 	AppIdDesc    = "app bundle ID"
 
+	CAFileFlag    = "ca-file"
+	CAFileDefault = ""
+	CAFileDesc    = "path to PEM file with additional CA certificates to trust (e.g. from 'apnstool mock-server')"
+
+	CollapseIdFlag    = "collapse-id"
+	CollapseIdDefault = ""
+	CollapseIdDesc    = "value for 'apns-collapse-id' header"
+
 	DataStringFlag      = "data"
 	DataStringShortFlag = "d"
 	DataStringDefault   = ""
-	DataStringDesc      = "JSON formatted notification content"
+	DataStringDesc      = "JSON formatted notification content ('@path' to read from a file, '-' to read from stdin)"
+
+	DataFileFlag    = "data-file"
+	DataFileDefault = ""
+	DataFileDesc    = "path to file with JSON formatted notification content ('-' to read from stdin)"
 
 	DeviceTokenFlag    = "device-token"
 	DeviceTokenDefault = ""
 	DeviceTokenDesc    = "APNs device token"
 
+	DryRunFlag    = "dry-run"
+	DryRunDefault = false
+	DryRunDesc    = "print the request that would be sent without sending it"
+
+	ExpirationFlag    = "expiration"
+	ExpirationDefault = ""
+	ExpirationDesc    = "value for 'apns-expiration' header: UNIX time, RFC 3339 time, or duration from now (e.g. 1h); 0 to attempt delivery only once"
+
+	HeaderFlag      = "header"
+	HeaderShortFlag = "H"
+	HeaderDesc      = "additional 'apns-' header as name=value (repeatable)"
+
+	EndpointFlag    = "endpoint"
+	EndpointDefault = ""
+	EndpointDesc    = "APNs host[:port] to send to (overrides --sandbox)"
+
+	NoValidateFlag    = "no-validate"
+	NoValidateDefault = false
+	NoValidateDesc    = "send the notification even if it fails validation"
+
+	PriorityFlag    = "priority"
+	PriorityDefault = 0
+	PriorityDesc    = "value for 'apns-priority' header (10, 5 or 1)"
+
+	RetriesFlag    = "retries"
+	RetriesDefault = 0
+	RetriesDesc    = "number of times to retry when APNs returns a transient error"
+
+	RetryBackoffFlag    = "retry-backoff"
+	RetryBackoffDefault = 500 * time.Millisecond
+	RetryBackoffDesc    = "initial backoff between retries (doubles after each retry, with jitter)"
+
+	ShowSecretsFlag    = "show-secrets"
+	ShowSecretsDefault = false
+	ShowSecretsDesc    = "print the provider token instead of redacting it (with --dry-run)"
+
 	SandboxFlag    = "sandbox"
 	SandboxDefault = false
 	SandboxDesc    = "use APNS sandbox endpoint"
 
+	TimeoutFlag    = "timeout"
+	TimeoutDefault = 30 * time.Second
+	TimeoutDesc    = "maximum time to wait for APNs to respond (0 to wait indefinitely)"
+
 	VerboseFlag      = "verbose"
 	VerboseShortFlag = "v"
 	VerboseDefault   = false
@@ -42,10 +108,23 @@ const (
 )
 
 type SendCmd struct {
+	ApnsId          string
 	AppId           string
+	CAFile          string
 	CertificateAuth auth.CertificateAuth
+	CollapseId      string
 	DeviceToken     string
+	DryRun          bool
+	Endpoint        string
+	Expiration      string
+	Headers         []string
+	NoValidate      bool
+	Priority        int
+	Retries         int
+	RetryBackoff    time.Duration
 	Sandbox         bool
+	ShowSecrets     bool
+	Timeout         time.Duration
 	TokenAuth       auth.TokenAuth
 	Verbose         bool
 
@@ -53,62 +132,342 @@ type SendCmd struct {
 	IO     cmdio.CmdIO
 }
 
+// DataFlags holds the notification content given with --data or --data-file.
+type DataFlags struct {
+	DataString string
+	DataFile   string
+}
+
+func BindDataFlags(flags *pflag.FlagSet, data *DataFlags) {
+	flags.StringVarP(&data.DataString, DataStringFlag, DataStringShortFlag, DataStringDefault, DataStringDesc)
+	flags.StringVar(&data.DataFile, DataFileFlag, DataFileDefault, DataFileDesc)
+	cmdio.BindEnv(flags, DataStringFlag, DataFileFlag)
+}
+
+// IsSet reports whether notification content was given.
+func (data *DataFlags) IsSet() bool {
+	return data.DataString != "" || data.DataFile != ""
+}
+
+// ReadsStdin reports whether the notification content is read from stdin.
+func (data *DataFlags) ReadsStdin() bool {
+	return data.DataString == "-" || data.DataString == "@-" || data.DataFile == "-"
+}
+
+// Read returns the notification content, reading it from a file or stdin if
+// requested, and checks that it is a JSON object.
+func (data *DataFlags) Read(stdin io.Reader) ([]byte, map[string]interface{}, error) {
+	if data.DataString != "" && data.DataFile != "" {
+		return nil, nil, fmt.Errorf("only one of --%s and --%s may be given", DataStringFlag, DataFileFlag)
+	}
+
+	var content []byte
+	var err error
+
+	switch {
+	case data.DataFile != "":
+		content, err = readFileOrStdin(data.DataFile, stdin)
+	case data.DataString == "-":
+		content, err = ioutil.ReadAll(stdin)
+	case strings.HasPrefix(data.DataString, "@"):
+		content, err = readFileOrStdin(data.DataString[1:], stdin)
+	default:
+		content = []byte(data.DataString)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := parseDataString(string(content))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid notification content: %s", err)
+	}
+
+	return content, parsed, nil
+}
+
+func readFileOrStdin(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// BindDeviceTokenFlag binds --device-token for the commands that send to a
+// single device. send batch reads device tokens from its input instead.
+func BindDeviceTokenFlag(flags *pflag.FlagSet, cmd *SendCmd) {
+	flags.StringVar(&cmd.DeviceToken, DeviceTokenFlag, DeviceTokenDefault, DeviceTokenDesc)
+	cmdio.BindEnv(flags, DeviceTokenFlag)
+}
+
 func BindSendCommonFlags(flags *pflag.FlagSet, cmd *SendCmd) {
 	auth.BindTokenAuthFlags(flags, &cmd.TokenAuth)
 	auth.BindCertificateAuthFlags(flags, &cmd.CertificateAuth)
+	flags.StringVar(&cmd.ApnsId, ApnsIdFlag, ApnsIdDefault, ApnsIdDesc)
 	flags.StringVar(&cmd.AppId, AppIdFlag, AppIdDefault, AppIdDesc)
-	flags.StringVar(&cmd.DeviceToken, DeviceTokenFlag, DeviceTokenDefault, DeviceTokenDesc)
+	flags.StringVar(&cmd.CAFile, CAFileFlag, CAFileDefault, CAFileDesc)
+	flags.StringVar(&cmd.CollapseId, CollapseIdFlag, CollapseIdDefault, CollapseIdDesc)
+	flags.BoolVar(&cmd.DryRun, DryRunFlag, DryRunDefault, DryRunDesc)
+	flags.StringVar(&cmd.Endpoint, EndpointFlag, EndpointDefault, EndpointDesc)
+	flags.StringVar(&cmd.Expiration, ExpirationFlag, ExpirationDefault, ExpirationDesc)
+	flags.StringArrayVarP(&cmd.Headers, HeaderFlag, HeaderShortFlag, nil, HeaderDesc)
+	flags.BoolVar(&cmd.NoValidate, NoValidateFlag, NoValidateDefault, NoValidateDesc)
+	flags.IntVar(&cmd.Priority, PriorityFlag, PriorityDefault, PriorityDesc)
+	flags.IntVar(&cmd.Retries, RetriesFlag, RetriesDefault, RetriesDesc)
+	flags.DurationVar(&cmd.RetryBackoff, RetryBackoffFlag, RetryBackoffDefault, RetryBackoffDesc)
 	flags.BoolVar(&cmd.Sandbox, SandboxFlag, SandboxDefault, SandboxDesc)
+	flags.BoolVar(&cmd.ShowSecrets, ShowSecretsFlag, ShowSecretsDefault, ShowSecretsDesc)
+	flags.DurationVar(&cmd.Timeout, TimeoutFlag, TimeoutDefault, TimeoutDesc)
 	flags.BoolVarP(&cmd.Verbose, VerboseFlag, VerboseShortFlag, VerboseDefault, VerboseDesc)
+	cmdio.BindEnv(flags,
+		ApnsIdFlag,
+		AppIdFlag,
+		CAFileFlag,
+		CollapseIdFlag,
+		DryRunFlag,
+		EndpointFlag,
+		ExpirationFlag,
+		HeaderFlag,
+		NoValidateFlag,
+		PriorityFlag,
+		RetriesFlag,
+		RetryBackoffFlag,
+		SandboxFlag,
+		ShowSecretsFlag,
+		TimeoutFlag,
+		VerboseFlag,
+	)
+}
+
+// configureHeaders applies the header flags to the builder. Headers given with
+// --header are applied last and override all others.
+func (cmd *SendCmd) configureHeaders(builder *apns.NotificationBuilder) error {
+	if cmd.ApnsId != "" {
+		builder.SetNotificationId(cmd.ApnsId)
+	}
+
+	if cmd.CollapseId != "" {
+		builder.SetCollapseId(cmd.CollapseId)
+	}
+
+	if cmd.Expiration != "" {
+		expiration, err := parseExpiration(cmd.Expiration)
+		if err != nil {
+			return err
+		}
+		builder.SetExpiration(expiration)
+	}
+
+	if cmd.Priority != 0 {
+		if cmd.Priority != 1 && cmd.Priority != 5 && cmd.Priority != 10 {
+			return fmt.Errorf("invalid --%s %d (must be 10, 5 or 1)", PriorityFlag, cmd.Priority)
+		}
+		builder.SetPriority(cmd.Priority)
+	}
+
+	for _, header := range cmd.Headers {
+		name, value, err := ParseHeader(header)
+		if err != nil {
+			return err
+		}
+		builder.SetHeader(name, value)
+	}
+
+	return nil
 }
 
 func (cmd *SendCmd) sendNotification(
 	headers apns.Headers,
 	content []byte,
 ) error {
+	defer cmd.Client.Close()
+
+	if !cmd.NoValidate {
+		if err := cmd.validate(headers, content); err != nil {
+			return err
+		}
+	}
+
+	if err := cmd.configureClient(cmd.Client, cmd.endpoint()); err != nil {
+		return err
+	}
+
+	if cmd.DryRun {
+		return cmd.printRequest(cmd.Client, cmd.DeviceToken, headers, content)
+	}
+
+	ctx, cancel := cmd.newContext()
+	defer cancel()
+
+	start := time.Now()
+
+	result, err := cmd.Client.SendContext(ctx, cmd.DeviceToken, headers, content)
+	if err != nil {
+		return cmdio.NewExitCodeError(cmdio.ExitTransportError, err)
+	}
+
+	output := newSendOutput(result, cmd.endpoint(), headers[apns.HeaderPushType], time.Since(start))
+	if err := cmd.IO.Output(output); err != nil {
+		return err
+	}
+
+	if apnsErr := result.APNsError(); apnsErr != nil {
+		return cmdio.NewExitCodeError(exitCodeForReason(apnsErr.Reason), apnsErr)
+	}
+
+	return nil
+}
+
+// configureClient applies the connection, retry and authentication flags to
+// the client. An empty endpoint leaves the client's default.
+func (cmd *SendCmd) configureClient(client apns.Client, endpoint string) error {
 	if cmd.Verbose {
-		cmd.Client.EnableLogging(cmd.IO.Stdout())
+		client.EnableLogging(cmd.IO.Log())
 	}
 
-	if cmd.Sandbox {
-		cmd.Client.ConfigureEndpoint(apns.SandboxEndpoint)
+	if endpoint != "" {
+		client.ConfigureEndpoint(endpoint)
+	}
+
+	if cmd.CAFile != "" {
+		rootCAs, err := LoadCAFile(cmd.CAFile)
+		if err != nil {
+			return err
+		}
+
+		client.ConfigureRootCAs(rootCAs)
+	}
+
+	if cmd.Retries > 0 {
+		client.ConfigureRetryPolicy(apns.RetryPolicy{
+			MaxAttempts:    cmd.Retries + 1,
+			InitialBackoff: cmd.RetryBackoff,
+			MaxBackoff:     apns.DefaultRetryPolicy.MaxBackoff,
+		})
 	}
 
 	if cmd.useTokenAuth() {
-		token, err := apns.GenerateJWTFromKeyFile(
+		tokenProvider, err := apns.NewKeyTokenProviderFromFile(
 			cmd.TokenAuth.KeyFile,
 			cmd.TokenAuth.KeyId,
 			cmd.TokenAuth.TeamId,
-			time.Now(),
-			cmd.TokenAuth.ExpiresAfter,
 		)
 		if err != nil {
-			return err
+			return cmdio.NewExitCodeError(cmdio.ExitAuthError, err)
 		}
+		tokenProvider.ExpiresAfter = cmd.TokenAuth.ExpiresAfter
 
-		cmd.Client.ConfigureTokenAuth(token)
+		client.ConfigureTokenProvider(tokenProvider)
 	} else if cmd.useCertificateAuth() {
-		cert, err := apns.LoadCertificateFromFile(cmd.CertificateAuth.CertificateFile, cmd.CertificateAuth.CertificatePassword)
+		cert, err := cmd.CertificateAuth.Load()
 		if err != nil {
-			return err
+			return cmdio.NewExitCodeError(cmdio.ExitAuthError, err)
+		}
+
+		if err := apns.CheckCertificateExpiry(cert, time.Now()); err != nil {
+			return cmdio.NewExitCodeError(cmdio.ExitAuthError, err)
 		}
 
-		cmd.Client.ConfigureCertificateAuth(cert)
+		client.ConfigureCertificateAuth(cert)
+
+		if cmd.DryRun {
+			cmd.printCertificate(cert)
+		}
 	}
 
-	result, err := cmd.Client.Send(cmd.DeviceToken, headers, content)
-	if err != nil {
-		return err
+	return nil
+}
+
+// endpoint returns the endpoint selected by --endpoint or --sandbox, or an
+// empty string for the client's default.
+func (cmd *SendCmd) endpoint() string {
+	if cmd.Endpoint != "" {
+		return cmd.Endpoint
+	} else if cmd.Sandbox {
+		return apns.SandboxEndpoint
 	}
+	return ""
+}
 
-	if result.Success() {
-		cmd.IO.Out("Notification sent successfully\n")
-		cmd.IO.Outf("APNS-ID: %s\n", result.Id())
+// validate prints the problems apns.Validate finds with the notification and
+// fails if any of them would make APNs reject it.
+func (cmd *SendCmd) validate(headers apns.Headers, content []byte) error {
+	issues := apns.Validate(headers, content)
+	for _, issue := range issues {
+		fmt.Fprintf(cmd.IO.Log(), "%s\n", issue)
+	}
+
+	if issues.HasErrors() {
+		return cmdio.NewExitCodeError(cmdio.ExitPayloadError,
+			fmt.Errorf("notification failed validation (use --%s to send anyway)", NoValidateFlag))
 	}
 
 	return nil
 }
 
+// exitCodeForReason groups APNs error reasons into the process exit codes
+// documented in cmdio.
+func exitCodeForReason(reason apns.ErrorReason) int {
+	switch reason {
+	case apns.ReasonBadCertificate,
+		apns.ReasonBadCertificateEnvironment,
+		apns.ReasonExpiredProviderToken,
+		apns.ReasonForbidden,
+		apns.ReasonInvalidProviderToken,
+		apns.ReasonMissingProviderToken,
+		apns.ReasonTopicDisallowed,
+		apns.ReasonTooManyProviderTokenUpdates:
+		return cmdio.ExitAuthError
+	case apns.ReasonBadDeviceToken,
+		apns.ReasonDeviceTokenNotForTopic,
+		apns.ReasonExpiredToken,
+		apns.ReasonMissingDeviceToken,
+		apns.ReasonUnregistered:
+		return cmdio.ExitBadDeviceToken
+	case apns.ReasonBadCollapseId,
+		apns.ReasonBadExpirationDate,
+		apns.ReasonBadMessageId,
+		apns.ReasonBadPriority,
+		apns.ReasonBadTopic,
+		apns.ReasonDuplicateHeaders,
+		apns.ReasonInvalidPushType,
+		apns.ReasonMissingTopic,
+		apns.ReasonPayloadEmpty,
+		apns.ReasonPayloadTooLarge:
+		return cmdio.ExitPayloadError
+	}
+	return cmdio.ExitRejected
+}
+
+// newContext returns a context that is canceled when the configured timeout
+// elapses or the process receives an interrupt signal.
+func (cmd *SendCmd) newContext() (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	if cmd.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), cmd.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
 func (cmd *SendCmd) useCertificateAuth() bool {
 	return cmd.CertificateAuth.CertificateFile != ""
 }
@@ -119,6 +478,69 @@ func (cmd *SendCmd) useTokenAuth() bool {
 		cmd.TokenAuth.TeamId != ""
 }
 
+// parseExpiration accepts a UNIX time, an RFC 3339 time or a duration from
+// now. "0" yields the zero time.
+func parseExpiration(value string) (time.Time, error) {
+	if value == "0" {
+		return time.Time{}, nil
+	}
+	return parseTime(ExpirationFlag, value)
+}
+
+// parseTime parses the value of a time flag given as a UNIX time, an RFC 3339
+// time or a duration from now.
+func parseTime(flagName string, value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --%s %q (must be a UNIX time, RFC 3339 time or duration)", flagName, value)
+}
+
+// ParseHeader splits a name=value header flag and checks that the name is a
+// header APNs understands.
+func ParseHeader(header string) (string, string, error) {
+	parts := strings.SplitN(header, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("invalid --%s %q (must be name=value)", HeaderFlag, header)
+	}
+
+	name := strings.ToLower(strings.TrimSpace(parts[0]))
+	if !apns.IsKnownHeader(name) {
+		return "", "", fmt.Errorf("unknown APNs header %q", name)
+	}
+
+	return name, strings.TrimSpace(parts[1]), nil
+}
+
+// LoadCAFile returns the system certificate pool extended with the
+// certificates in the given PEM file.
+func LoadCAFile(caFile string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+
+	if !rootCAs.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificates found in " + caFile)
+	}
+
+	return rootCAs, nil
+}
+
 func parseDataString(dataString string) (map[string]interface{}, error) {
 	data := make(map[string]interface{})
 
@@ -141,7 +563,14 @@ func GetCommand() *cobra.Command {
 
 	sendCmd.AddCommand(NewSendAlertCommand())
 	sendCmd.AddCommand(NewSendBackgroundCommand())
+	sendCmd.AddCommand(NewSendBatchCommand())
+	sendCmd.AddCommand(NewSendLiveActivityCommand())
+	sendCmd.AddCommand(NewSendMdmCommand())
 	sendCmd.AddCommand(NewSendRawCommand())
 
+	for _, spec := range pushTypeSpecs {
+		sendCmd.AddCommand(NewSendPushTypeCommand(spec))
+	}
+
 	return sendCmd
 }
@@ -5,6 +5,8 @@
 package send
 
 import (
+	"fmt"
+
 	"github.com/brannon/apnstool/apns"
 	"github.com/brannon/apnstool/cmdio"
 	"github.com/spf13/cobra"
@@ -19,17 +21,112 @@ const (
 	BadgeCountDefault = 0
 	BadgeCountDesc    = "badge count"
 
+	CategoryFlag    = "category"
+	CategoryDefault = ""
+	CategoryDesc    = "notification category (selects the actions shown)"
+
+	CriticalFlag    = "critical"
+	CriticalDefault = false
+	CriticalDesc    = "send as a critical alert (requires the critical alerts entitlement)"
+
+	BodyFlag    = "body"
+	BodyDefault = ""
+	BodyDesc    = "alert body (same as --alert-text)"
+
+	FilterCriteriaFlag    = "filter-criteria"
+	FilterCriteriaDefault = ""
+	FilterCriteriaDesc    = "criteria used by the app's Focus filter"
+
+	InterruptionLevelFlag    = "interruption-level"
+	InterruptionLevelDefault = ""
+	InterruptionLevelDesc    = "interruption level: passive, active, time-sensitive or critical"
+
+	LaunchImageFlag    = "launch-image"
+	LaunchImageDefault = ""
+	LaunchImageDesc    = "name of the launch image to show when the app opens from the alert"
+
+	LocArgsFlag = "loc-args"
+	LocArgsDesc = "values for the format specifiers in the --loc-key string"
+
+	LocKeyFlag    = "loc-key"
+	LocKeyDefault = ""
+	LocKeyDesc    = "key of a localized alert body string"
+
+	MutableContentFlag    = "mutable-content"
+	MutableContentDefault = false
+	MutableContentDesc    = "let the app's notification service extension modify the notification"
+
+	RelevanceScoreFlag    = "relevance-score"
+	RelevanceScoreDefault = 0.0
+	RelevanceScoreDesc    = "relevance score in the notification summary, from 0.0 to 1.0"
+
 	SoundNameFlag    = "sound-name"
 	SoundNameDefault = ""
 	SoundNameDesc    = "sound name"
+
+	SoundVolumeFlag    = "sound-volume"
+	SoundVolumeDefault = 1.0
+	SoundVolumeDesc    = "critical alert sound volume, from 0.0 to 1.0"
+
+	SubtitleFlag    = "subtitle"
+	SubtitleDefault = ""
+	SubtitleDesc    = "alert subtitle"
+
+	SubtitleLocArgsFlag = "subtitle-loc-args"
+	SubtitleLocArgsDesc = "values for the format specifiers in the --subtitle-loc-key string"
+
+	SubtitleLocKeyFlag    = "subtitle-loc-key"
+	SubtitleLocKeyDefault = ""
+	SubtitleLocKeyDesc    = "key of a localized alert subtitle string"
+
+	TargetContentIdFlag    = "target-content-id"
+	TargetContentIdDefault = ""
+	TargetContentIdDesc    = "identifier of the window to bring forward when the notification is opened"
+
+	ThreadIdFlag    = "thread-id"
+	ThreadIdDefault = ""
+	ThreadIdDesc    = "identifier used to group related notifications"
+
+	TitleFlag    = "title"
+	TitleDefault = ""
+	TitleDesc    = "alert title"
+
+	TitleLocArgsFlag = "title-loc-args"
+	TitleLocArgsDesc = "values for the format specifiers in the --title-loc-key string"
+
+	TitleLocKeyFlag    = "title-loc-key"
+	TitleLocKeyDefault = ""
+	TitleLocKeyDesc    = "key of a localized alert title string"
 )
 
 type SendAlertCmd struct {
 	SendCmd
 
-	AlertText  string
-	BadgeCount int
-	SoundName  string
+	AlertText         string
+	BadgeCount        int
+	Body              string
+	Category          string
+	Critical          bool
+	FilterCriteria    string
+	InterruptionLevel string
+	LaunchImage       string
+	LocArgs           []string
+	LocKey            string
+	MutableContent    bool
+	RelevanceScore    float64
+	SoundName         string
+	SoundVolume       float64
+	Subtitle          string
+	SubtitleLocArgs   []string
+	SubtitleLocKey    string
+	TargetContentId   string
+	ThreadId          string
+	Title             string
+	TitleLocArgs      []string
+	TitleLocKey       string
+
+	relevanceScoreSet bool
+	soundVolumeSet    bool
 }
 
 func NewSendAlertCommand() *cobra.Command {
@@ -41,6 +138,8 @@ func NewSendAlertCommand() *cobra.Command {
 		RunE: func(c *cobra.Command, args []string) error {
 			cmd.Client = apns.NewClient()
 			cmd.IO = cmdio.NewCmdIO(c.OutOrStdout())
+			cmd.relevanceScoreSet = c.Flags().Changed(RelevanceScoreFlag)
+			cmd.soundVolumeSet = c.Flags().Changed(SoundVolumeFlag)
 
 			return cmd.Run()
 		},
@@ -48,9 +147,29 @@ func NewSendAlertCommand() *cobra.Command {
 
 	flags := cobraCmd.Flags()
 	BindSendCommonFlags(flags, &cmd.SendCmd)
+	BindDeviceTokenFlag(flags, &cmd.SendCmd)
 	flags.StringVar(&cmd.AlertText, AlertTextFlag, AlertTextDefault, AlertTextDesc)
 	flags.IntVar(&cmd.BadgeCount, BadgeCountFlag, BadgeCountDefault, BadgeCountDesc)
+	flags.StringVar(&cmd.Body, BodyFlag, BodyDefault, BodyDesc)
+	flags.StringVar(&cmd.Category, CategoryFlag, CategoryDefault, CategoryDesc)
+	flags.BoolVar(&cmd.Critical, CriticalFlag, CriticalDefault, CriticalDesc)
+	flags.StringVar(&cmd.FilterCriteria, FilterCriteriaFlag, FilterCriteriaDefault, FilterCriteriaDesc)
+	flags.StringVar(&cmd.InterruptionLevel, InterruptionLevelFlag, InterruptionLevelDefault, InterruptionLevelDesc)
+	flags.StringVar(&cmd.LaunchImage, LaunchImageFlag, LaunchImageDefault, LaunchImageDesc)
+	flags.StringSliceVar(&cmd.LocArgs, LocArgsFlag, nil, LocArgsDesc)
+	flags.StringVar(&cmd.LocKey, LocKeyFlag, LocKeyDefault, LocKeyDesc)
+	flags.BoolVar(&cmd.MutableContent, MutableContentFlag, MutableContentDefault, MutableContentDesc)
+	flags.Float64Var(&cmd.RelevanceScore, RelevanceScoreFlag, RelevanceScoreDefault, RelevanceScoreDesc)
 	flags.StringVar(&cmd.SoundName, SoundNameFlag, SoundNameDefault, SoundNameDesc)
+	flags.Float64Var(&cmd.SoundVolume, SoundVolumeFlag, SoundVolumeDefault, SoundVolumeDesc)
+	flags.StringVar(&cmd.Subtitle, SubtitleFlag, SubtitleDefault, SubtitleDesc)
+	flags.StringSliceVar(&cmd.SubtitleLocArgs, SubtitleLocArgsFlag, nil, SubtitleLocArgsDesc)
+	flags.StringVar(&cmd.SubtitleLocKey, SubtitleLocKeyFlag, SubtitleLocKeyDefault, SubtitleLocKeyDesc)
+	flags.StringVar(&cmd.TargetContentId, TargetContentIdFlag, TargetContentIdDefault, TargetContentIdDesc)
+	flags.StringVar(&cmd.ThreadId, ThreadIdFlag, ThreadIdDefault, ThreadIdDesc)
+	flags.StringVar(&cmd.Title, TitleFlag, TitleDefault, TitleDesc)
+	flags.StringSliceVar(&cmd.TitleLocArgs, TitleLocArgsFlag, nil, TitleLocArgsDesc)
+	flags.StringVar(&cmd.TitleLocKey, TitleLocKeyFlag, TitleLocKeyDefault, TitleLocKeyDesc)
 
 	_ = cobraCmd.MarkFlagRequired(AppIdFlag)
 	_ = cobraCmd.MarkFlagRequired(DeviceTokenFlag)
@@ -59,12 +178,52 @@ func NewSendAlertCommand() *cobra.Command {
 }
 
 func (cmd *SendAlertCmd) Run() error {
+	if len(cmd.LocArgs) > 0 && cmd.LocKey == "" {
+		return fmt.Errorf("--%s requires --%s", LocArgsFlag, LocKeyFlag)
+	}
+
+	if len(cmd.SubtitleLocArgs) > 0 && cmd.SubtitleLocKey == "" {
+		return fmt.Errorf("--%s requires --%s", SubtitleLocArgsFlag, SubtitleLocKeyFlag)
+	}
+
+	if len(cmd.TitleLocArgs) > 0 && cmd.TitleLocKey == "" {
+		return fmt.Errorf("--%s requires --%s", TitleLocArgsFlag, TitleLocKeyFlag)
+	}
+
 	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
 
 	if cmd.AlertText != "" {
 		notificationBuilder.SetAlertText(cmd.AlertText)
 	}
 
+	if cmd.Body != "" {
+		notificationBuilder.SetAlertBody(cmd.Body)
+	}
+
+	if cmd.Title != "" {
+		notificationBuilder.SetAlertTitle(cmd.Title)
+	}
+
+	if cmd.Subtitle != "" {
+		notificationBuilder.SetAlertSubtitle(cmd.Subtitle)
+	}
+
+	if cmd.LaunchImage != "" {
+		notificationBuilder.SetAlertLaunchImage(cmd.LaunchImage)
+	}
+
+	if cmd.TitleLocKey != "" {
+		notificationBuilder.SetAlertTitleLocalization(cmd.TitleLocKey, cmd.TitleLocArgs)
+	}
+
+	if cmd.SubtitleLocKey != "" {
+		notificationBuilder.SetAlertSubtitleLocalization(cmd.SubtitleLocKey, cmd.SubtitleLocArgs)
+	}
+
+	if cmd.LocKey != "" {
+		notificationBuilder.SetAlertLocalization(cmd.LocKey, cmd.LocArgs)
+	}
+
 	if cmd.BadgeCount != -1 {
 		notificationBuilder.SetBadgeCount(cmd.BadgeCount)
 	}
@@ -73,6 +232,46 @@ func (cmd *SendAlertCmd) Run() error {
 		notificationBuilder.SetSoundName(cmd.SoundName)
 	}
 
+	if cmd.Critical {
+		notificationBuilder.SetCriticalSound(true)
+	}
+
+	if cmd.soundVolumeSet {
+		notificationBuilder.SetSoundVolume(cmd.SoundVolume)
+	}
+
+	if cmd.InterruptionLevel != "" {
+		notificationBuilder.SetInterruptionLevel(cmd.InterruptionLevel)
+	}
+
+	if cmd.relevanceScoreSet {
+		notificationBuilder.SetRelevanceScore(cmd.RelevanceScore)
+	}
+
+	if cmd.ThreadId != "" {
+		notificationBuilder.SetThreadId(cmd.ThreadId)
+	}
+
+	if cmd.Category != "" {
+		notificationBuilder.SetCategory(cmd.Category)
+	}
+
+	if cmd.TargetContentId != "" {
+		notificationBuilder.SetTargetContentId(cmd.TargetContentId)
+	}
+
+	if cmd.FilterCriteria != "" {
+		notificationBuilder.SetFilterCriteria(cmd.FilterCriteria)
+	}
+
+	if cmd.MutableContent {
+		notificationBuilder.SetMutableContent(true)
+	}
+
+	if err := cmd.configureHeaders(notificationBuilder); err != nil {
+		return err
+	}
+
 	headers, content, err := notificationBuilder.Build()
 	if err != nil {
 		return err
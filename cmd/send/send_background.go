@@ -12,8 +12,7 @@ import (
 
 type SendBackgroundCmd struct {
 	SendCmd
-
-	DataString string
+	DataFlags
 }
 
 func NewSendBackgroundCommand() *cobra.Command {
@@ -32,7 +31,8 @@ func NewSendBackgroundCommand() *cobra.Command {
 
 	flags := cobraCmd.Flags()
 	BindSendCommonFlags(flags, &cmd.SendCmd)
-	flags.StringVarP(&cmd.DataString, DataStringFlag, DataStringShortFlag, DataStringDefault, DataStringDesc)
+	BindDeviceTokenFlag(flags, &cmd.SendCmd)
+	BindDataFlags(flags, &cmd.DataFlags)
 
 	_ = cobraCmd.MarkFlagRequired(AppIdFlag)
 	_ = cobraCmd.MarkFlagRequired(DeviceTokenFlag)
@@ -44,8 +44,8 @@ func (cmd *SendBackgroundCmd) Run() error {
 	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
 	notificationBuilder.SetContentAvailable(true)
 
-	if cmd.DataString != "" {
-		data, err := parseDataString(cmd.DataString)
+	if cmd.DataFlags.IsSet() {
+		_, data, err := cmd.DataFlags.Read(cmd.IO.Stdin())
 		if err != nil {
 			return err
 		}
@@ -53,6 +53,10 @@ func (cmd *SendBackgroundCmd) Run() error {
 		notificationBuilder.Merge(data)
 	}
 
+	if err := cmd.configureHeaders(notificationBuilder); err != nil {
+		return err
+	}
+
 	headers, content, err := notificationBuilder.Build()
 	if err != nil {
 		return err
@@ -0,0 +1,395 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package send
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/brannon/apnstool/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ConcurrencyFlag    = "concurrency"
+	ConcurrencyDefault = apns.DefaultConcurrency
+	ConcurrencyDesc    = "maximum number of notifications in flight at once"
+
+	InputFlag    = "input"
+	InputDefault = ""
+	InputDesc    = "path to JSONL or CSV file with one device per row ('-' to read from stdin)"
+
+	InputFormatFlag    = "input-format"
+	InputFormatDefault = ""
+	InputFormatDesc    = "format of --input: jsonl or csv (default from the file extension)"
+
+	ResultsFileFlag    = "results"
+	ResultsFileDefault = ""
+	ResultsFileDesc    = "path to write per-row results to as JSONL"
+
+	ResumeFlag    = "resume"
+	ResumeDefault = ""
+	ResumeDesc    = "path to the results file of a previous run; only rows that did not succeed are sent"
+)
+
+const sendBatchLong = `Send a notification to each device listed in a JSONL or CSV file.
+
+Each JSONL line is an object with a device_token and optional environment
+("sandbox" or "production"), headers (an object of apns- headers) and payload
+(merged over the --data content):
+
+  {"device_token": "...", "payload": {"aps": {"alert": {"title": "Hi Ann"}}}}
+
+A CSV file has a header row. The device_token, environment and payload (JSON)
+columns work as above; apns- columns set headers; any other column sets the
+payload field at its dotted path, e.g. aps.alert.title.
+
+The --timeout applies to the whole batch.`
+
+type SendBatchCmd struct {
+	SendCmd
+	DataFlags
+
+	Concurrency int
+	Input       string
+	InputFormat string
+	ResultsFile string
+	Resume      string
+}
+
+func NewSendBatchCommand() *cobra.Command {
+	cmd := &SendBatchCmd{}
+
+	cobraCmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Send notifications to a list of devices through APNs",
+		Long:  sendBatchLong,
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.IO = cmdio.NewCmdIO(c.OutOrStdout())
+
+			return cmd.Run()
+		},
+	}
+
+	flags := cobraCmd.Flags()
+	BindSendCommonFlags(flags, &cmd.SendCmd)
+	BindDataFlags(flags, &cmd.DataFlags)
+	flags.IntVar(&cmd.Concurrency, ConcurrencyFlag, ConcurrencyDefault, ConcurrencyDesc)
+	flags.StringVar(&cmd.Input, InputFlag, InputDefault, InputDesc)
+	flags.StringVar(&cmd.InputFormat, InputFormatFlag, InputFormatDefault, InputFormatDesc)
+	flags.StringVar(&cmd.ResultsFile, ResultsFileFlag, ResultsFileDefault, ResultsFileDesc)
+	flags.StringVar(&cmd.Resume, ResumeFlag, ResumeDefault, ResumeDesc)
+
+	_ = cobraCmd.MarkFlagRequired(AppIdFlag)
+	_ = cobraCmd.MarkFlagRequired(InputFlag)
+
+	return cobraCmd
+}
+
+// batchGroup is the set of rows sent through the same endpoint.
+type batchGroup struct {
+	endpoint      string
+	rows          []*batchRow
+	notifications []apns.Notification
+}
+
+func (cmd *SendBatchCmd) Run() error {
+	if cmd.Input == "-" && cmd.DataFlags.ReadsStdin() {
+		return fmt.Errorf("--%s and the notification content cannot both be read from stdin", InputFlag)
+	}
+
+	var data []byte
+	if cmd.DataFlags.IsSet() {
+		var err error
+		if data, _, err = cmd.DataFlags.Read(cmd.IO.Stdin()); err != nil {
+			return err
+		}
+	}
+
+	rows, err := readBatchRows(cmd.Input, cmd.InputFormat, cmd.IO.Stdin())
+	if err != nil {
+		return err
+	}
+
+	var results []*batchResult
+
+	if cmd.Resume != "" {
+		previous, err := readBatchResults(cmd.Resume)
+		if err != nil {
+			return err
+		}
+
+		pending := rows[:0]
+		for _, row := range rows {
+			if result, ok := previous[row.Row]; ok && result.succeeded() && result.DeviceToken == row.DeviceToken {
+				results = append(results, result)
+			} else {
+				pending = append(pending, row)
+			}
+		}
+
+		fmt.Fprintf(cmd.IO.Log(), "Resuming: %d rows already succeeded, %d to send\n", len(results), len(pending))
+		rows = pending
+	}
+
+	groups := make(map[string]*batchGroup)
+	var endpoints []string
+
+	for _, row := range rows {
+		headers, content, err := cmd.buildRow(row, data)
+		if err != nil {
+			results = append(results, &batchResult{
+				Row:         row.Row,
+				DeviceToken: row.DeviceToken,
+				Environment: row.Environment,
+				Error:       err.Error(),
+			})
+			continue
+		}
+
+		endpoint := cmd.rowEndpoint(row)
+		group, ok := groups[endpoint]
+		if !ok {
+			group = &batchGroup{endpoint: endpoint}
+			groups[endpoint] = group
+			endpoints = append(endpoints, endpoint)
+		}
+
+		group.rows = append(group.rows, row)
+		group.notifications = append(group.notifications, apns.Notification{
+			DeviceToken: row.DeviceToken,
+			Headers:     headers,
+			Content:     content,
+		})
+	}
+
+	ctx, cancel := cmd.newContext()
+	defer cancel()
+
+	var dryRunOutputs DryRunOutputs
+
+	for _, endpoint := range endpoints {
+		group := groups[endpoint]
+
+		client := apns.NewClient()
+		if err := cmd.configureClient(client, group.endpoint); err != nil {
+			// Keep the results of the groups already sent so --resume
+			// does not send them again.
+			if cmd.ResultsFile != "" && !cmd.DryRun {
+				if writeErr := writeBatchResults(cmd.ResultsFile, results); writeErr != nil {
+					fmt.Fprintf(cmd.IO.Log(), "Error writing %s: %v\n", cmd.ResultsFile, writeErr)
+				}
+			}
+			return err
+		}
+		client.ConfigureConcurrency(cmd.Concurrency)
+
+		if cmd.DryRun {
+			for _, notification := range group.notifications {
+				output, err := cmd.newDryRunOutput(client, notification.DeviceToken, notification.Headers, notification.Content)
+				if err != nil {
+					return err
+				}
+				dryRunOutputs = append(dryRunOutputs, output)
+			}
+			continue
+		}
+
+		sendResults := client.SendBatch(ctx, group.notifications)
+		_ = client.Close()
+
+		for i, sendResult := range sendResults {
+			results = append(results, newBatchResult(group.rows[i], sendResult))
+		}
+	}
+
+	if cmd.DryRun {
+		if err := cmd.IO.Output(dryRunOutputs); err != nil {
+			return err
+		}
+		return rowErrors(results)
+	}
+
+	if cmd.ResultsFile != "" {
+		if err := writeBatchResults(cmd.ResultsFile, results); err != nil {
+			return err
+		}
+	}
+
+	return cmd.printSummary(results)
+}
+
+// buildRow builds the notification for a row, merging the row's payload
+// over the --data content and applying the row's headers after the header
+// flags.
+func (cmd *SendBatchCmd) buildRow(row *batchRow, data []byte) (apns.Headers, []byte, error) {
+	content := make(map[string]interface{})
+	if data != nil {
+		var err error
+		if content, err = parseDataString(string(data)); err != nil {
+			return nil, nil, err
+		}
+	}
+	content = mergeContent(content, row.Payload)
+
+	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
+	notificationBuilder.Merge(content)
+
+	if err := cmd.configureHeaders(notificationBuilder); err != nil {
+		return nil, nil, err
+	}
+
+	for name, value := range row.Headers {
+		if !apns.IsKnownHeader(name) {
+			return nil, nil, fmt.Errorf("unknown APNs header %q", name)
+		}
+		notificationBuilder.SetHeader(name, value)
+	}
+
+	headers, payload, err := notificationBuilder.Build()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !cmd.NoValidate {
+		for _, issue := range apns.Validate(headers, payload) {
+			if issue.Severity == apns.SeverityError {
+				return nil, nil, fmt.Errorf("failed validation: %s", issue.Message)
+			}
+		}
+	}
+
+	return headers, payload, nil
+}
+
+// rowErrors reports the rows that could not be built, which --dry-run
+// does not print a request for.
+func rowErrors(results []*batchResult) error {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Row < results[j].Row
+	})
+
+	var messages []string
+	for _, result := range results {
+		if result.Error != "" {
+			messages = append(messages, fmt.Sprintf("row %d: %s", result.Row, result.Error))
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return cmdio.NewExitCodeError(cmdio.ExitPayloadError, fmt.Errorf("could not build %s", strings.Join(messages, "; ")))
+}
+
+// rowEndpoint returns the endpoint to send a row to. --endpoint overrides
+// the row's environment, which overrides --sandbox.
+func (cmd *SendBatchCmd) rowEndpoint(row *batchRow) string {
+	if cmd.Endpoint != "" {
+		return cmd.Endpoint
+	}
+
+	switch row.Environment {
+	case config.EnvironmentSandbox:
+		return apns.SandboxEndpoint
+	case config.EnvironmentProduction:
+		return apns.ProductionEndpoint
+	}
+
+	return cmd.endpoint()
+}
+
+func newBatchResult(row *batchRow, sendResult *apns.SendResult) *batchResult {
+	result := &batchResult{
+		Row:         row.Row,
+		DeviceToken: row.DeviceToken,
+		Environment: row.Environment,
+		Status:      sendResult.StatusCode,
+	}
+
+	if sendResult.Err != nil {
+		result.Error = sendResult.Err.Error()
+	} else {
+		result.Reason = string(sendResult.ErrorReason())
+		result.ApnsId = sendResult.Id()
+	}
+
+	return result
+}
+
+// BatchOutput summarizes a batch send, with the result of each row.
+type BatchOutput struct {
+	Total     int            `json:"total" yaml:"total"`
+	Succeeded int            `json:"succeeded" yaml:"succeeded"`
+	Failed    int            `json:"failed" yaml:"failed"`
+	Results   []*batchResult `json:"results" yaml:"results"`
+}
+
+func (output *BatchOutput) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%d notifications: %d succeeded, %d failed\n", output.Total, output.Succeeded, output.Failed)
+
+	for _, result := range output.Results {
+		if result.succeeded() {
+			continue
+		}
+
+		reason := result.Reason
+		if result.Error != "" {
+			reason = result.Error
+		}
+
+		status := ""
+		if result.Status != 0 {
+			status = fmt.Sprintf("%d ", result.Status)
+		}
+
+		fmt.Fprintf(w, "  row %d %s: %s%s\n", result.Row, shortToken(result.DeviceToken), status, reason)
+	}
+}
+
+func (cmd *SendBatchCmd) printSummary(results []*batchResult) error {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Row < results[j].Row
+	})
+
+	output := &BatchOutput{
+		Total:   len(results),
+		Results: results,
+	}
+	for _, result := range results {
+		if result.succeeded() {
+			output.Succeeded++
+		} else {
+			output.Failed++
+		}
+	}
+
+	if err := cmd.IO.Output(output); err != nil {
+		return err
+	}
+
+	if output.Failed > 0 {
+		hint := ""
+		if cmd.ResultsFile != "" {
+			hint = fmt.Sprintf(" (use --%s %s to retry them)", ResumeFlag, cmd.ResultsFile)
+		}
+		return cmdio.NewExitCodeError(cmdio.ExitRejected, fmt.Errorf("%d of %d notifications failed%s", output.Failed, output.Total, hint))
+	}
+
+	return nil
+}
+
+// shortToken abbreviates a device token for display.
+func shortToken(deviceToken string) string {
+	if len(deviceToken) <= 16 {
+		return deviceToken
+	}
+	return deviceToken[:8] + "..." + deviceToken[len(deviceToken)-8:]
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package send
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/spf13/cobra"
+)
+
+const (
+	AttributesFlag    = "attributes"
+	AttributesDefault = ""
+	AttributesDesc    = "JSON formatted attributes to start the Live Activity with"
+
+	AttributesTypeFlag    = "attributes-type"
+	AttributesTypeDefault = ""
+	AttributesTypeDesc    = "name of the app's ActivityAttributes type"
+
+	ContentStateFlag    = "content-state"
+	ContentStateDefault = ""
+	ContentStateDesc    = "JSON formatted content state of the Live Activity"
+
+	DismissalDateFlag    = "dismissal-date"
+	DismissalDateDefault = ""
+	DismissalDateDesc    = "when to remove the ended Live Activity: UNIX time, RFC 3339 time, or duration from now"
+
+	EventFlag    = "event"
+	EventDefault = ""
+	EventDesc    = "Live Activity event: start, update or end"
+
+	StaleDateFlag    = "stale-date"
+	StaleDateDefault = ""
+	StaleDateDesc    = "when the Live Activity becomes out of date: UNIX time, RFC 3339 time, or duration from now"
+
+	TimestampFlag    = "timestamp"
+	TimestampDefault = ""
+	TimestampDesc    = "when the content was generated: UNIX time, RFC 3339 time, or duration from now (default now)"
+)
+
+type SendLiveActivityCmd struct {
+	SendCmd
+
+	Attributes     string
+	AttributesType string
+	Body           string
+	ContentState   string
+	DismissalDate  string
+	Event          string
+	StaleDate      string
+	Timestamp      string
+	Title          string
+}
+
+func NewSendLiveActivityCommand() *cobra.Command {
+	cmd := &SendLiveActivityCmd{}
+
+	cobraCmd := &cobra.Command{
+		Use:   "liveactivity",
+		Short: "Send Live Activity notification through APNs",
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.Client = apns.NewClient()
+			cmd.IO = cmdio.NewCmdIO(c.OutOrStdout())
+
+			return cmd.Run()
+		},
+	}
+
+	flags := cobraCmd.Flags()
+	BindSendCommonFlags(flags, &cmd.SendCmd)
+	BindDeviceTokenFlag(flags, &cmd.SendCmd)
+	flags.StringVar(&cmd.Attributes, AttributesFlag, AttributesDefault, AttributesDesc)
+	flags.StringVar(&cmd.AttributesType, AttributesTypeFlag, AttributesTypeDefault, AttributesTypeDesc)
+	flags.StringVar(&cmd.Body, BodyFlag, BodyDefault, "alert body")
+	flags.StringVar(&cmd.ContentState, ContentStateFlag, ContentStateDefault, ContentStateDesc)
+	flags.StringVar(&cmd.DismissalDate, DismissalDateFlag, DismissalDateDefault, DismissalDateDesc)
+	flags.StringVar(&cmd.Event, EventFlag, EventDefault, EventDesc)
+	flags.StringVar(&cmd.StaleDate, StaleDateFlag, StaleDateDefault, StaleDateDesc)
+	flags.StringVar(&cmd.Timestamp, TimestampFlag, TimestampDefault, TimestampDesc)
+	flags.StringVar(&cmd.Title, TitleFlag, TitleDefault, TitleDesc)
+
+	_ = cobraCmd.MarkFlagRequired(AppIdFlag)
+	_ = cobraCmd.MarkFlagRequired(DeviceTokenFlag)
+	_ = cobraCmd.MarkFlagRequired(EventFlag)
+
+	return cobraCmd
+}
+
+func (cmd *SendLiveActivityCmd) Run() error {
+	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
+	notificationBuilder.SetEvent(cmd.Event)
+
+	timestamp := time.Now()
+	if cmd.Timestamp != "" {
+		var err error
+		if timestamp, err = parseTime(TimestampFlag, cmd.Timestamp); err != nil {
+			return err
+		}
+	}
+	notificationBuilder.SetTimestamp(timestamp)
+
+	if cmd.ContentState != "" {
+		contentState, err := parseDataString(cmd.ContentState)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %s", ContentStateFlag, err)
+		}
+		notificationBuilder.SetContentState(contentState)
+	}
+
+	if cmd.AttributesType != "" {
+		notificationBuilder.SetAttributesType(cmd.AttributesType)
+	}
+
+	if cmd.Attributes != "" {
+		attributes, err := parseDataString(cmd.Attributes)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %s", AttributesFlag, err)
+		}
+		notificationBuilder.SetAttributes(attributes)
+	}
+
+	if cmd.StaleDate != "" {
+		staleDate, err := parseTime(StaleDateFlag, cmd.StaleDate)
+		if err != nil {
+			return err
+		}
+		notificationBuilder.SetStaleDate(staleDate)
+	}
+
+	if cmd.DismissalDate != "" {
+		dismissalDate, err := parseTime(DismissalDateFlag, cmd.DismissalDate)
+		if err != nil {
+			return err
+		}
+		notificationBuilder.SetDismissalDate(dismissalDate)
+	}
+
+	if cmd.Title != "" {
+		notificationBuilder.SetAlertTitle(cmd.Title)
+	}
+
+	if cmd.Body != "" {
+		notificationBuilder.SetAlertBody(cmd.Body)
+	}
+
+	if err := cmd.configureHeaders(notificationBuilder); err != nil {
+		return err
+	}
+
+	headers, content, err := notificationBuilder.Build()
+	if err != nil {
+		return err
+	}
+
+	return cmd.sendNotification(headers, content)
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package send
+
+import (
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/spf13/cobra"
+)
+
+const (
+	PushMagicFlag    = "push-magic"
+	PushMagicDefault = ""
+	PushMagicDesc    = "PushMagic string the device sent when it enrolled"
+)
+
+type SendMdmCmd struct {
+	SendCmd
+
+	PushMagic string
+}
+
+func NewSendMdmCommand() *cobra.Command {
+	cmd := &SendMdmCmd{}
+
+	cobraCmd := &cobra.Command{
+		Use:   "mdm",
+		Short: "Send MDM wake-up notification through APNs",
+		Long: "Send MDM wake-up notification through APNs.\n\n" +
+			"The --app-id is used as the topic as given; it must be the MDM push topic " +
+			"(the UID of the MDM push certificate).",
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.Client = apns.NewClient()
+			cmd.IO = cmdio.NewCmdIO(c.OutOrStdout())
+
+			return cmd.Run()
+		},
+	}
+
+	flags := cobraCmd.Flags()
+	BindSendCommonFlags(flags, &cmd.SendCmd)
+	BindDeviceTokenFlag(flags, &cmd.SendCmd)
+	flags.StringVar(&cmd.PushMagic, PushMagicFlag, PushMagicDefault, PushMagicDesc)
+
+	_ = cobraCmd.MarkFlagRequired(AppIdFlag)
+	_ = cobraCmd.MarkFlagRequired(DeviceTokenFlag)
+	_ = cobraCmd.MarkFlagRequired(PushMagicFlag)
+
+	return cobraCmd
+}
+
+func (cmd *SendMdmCmd) Run() error {
+	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
+	notificationBuilder.SetPushType(apns.PushTypeMdm)
+	notificationBuilder.Merge(map[string]interface{}{
+		"mdm": cmd.PushMagic,
+	})
+
+	if err := cmd.configureHeaders(notificationBuilder); err != nil {
+		return err
+	}
+
+	headers, content, err := notificationBuilder.Build()
+	if err != nil {
+		return err
+	}
+
+	return cmd.sendNotification(headers, content)
+}
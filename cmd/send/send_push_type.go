@@ -0,0 +1,105 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package send
+
+import (
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/spf13/cobra"
+)
+
+// pushTypeSpec describes a send subcommand for a push type whose payload is
+// given with --data. The builder derives the topic suffix and priority the
+// push type requires from --app-id.
+type pushTypeSpec struct {
+	Use      string
+	Short    string
+	PushType string
+}
+
+var pushTypeSpecs = []pushTypeSpec{
+	{
+		Use:      "complication",
+		Short:    "Send watchOS complication notification through APNs",
+		PushType: apns.PushTypeComplication,
+	},
+	{
+		Use:      "fileprovider",
+		Short:    "Send File Provider notification through APNs",
+		PushType: apns.PushTypeFileProvider,
+	},
+	{
+		Use:      "location",
+		Short:    "Send location query notification through APNs",
+		PushType: apns.PushTypeLocation,
+	},
+	{
+		Use:      "pushtotalk",
+		Short:    "Send Push to Talk notification through APNs",
+		PushType: apns.PushTypePushToTalk,
+	},
+	{
+		Use:      "voip",
+		Short:    "Send VoIP notification through APNs",
+		PushType: apns.PushTypeVoip,
+	},
+}
+
+type SendPushTypeCmd struct {
+	SendCmd
+	DataFlags
+
+	PushType string
+}
+
+func NewSendPushTypeCommand(spec pushTypeSpec) *cobra.Command {
+	cmd := &SendPushTypeCmd{PushType: spec.PushType}
+
+	cobraCmd := &cobra.Command{
+		Use:   spec.Use,
+		Short: spec.Short,
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.Client = apns.NewClient()
+			cmd.IO = cmdio.NewCmdIO(c.OutOrStdout())
+
+			return cmd.Run()
+		},
+	}
+
+	flags := cobraCmd.Flags()
+	BindSendCommonFlags(flags, &cmd.SendCmd)
+	BindDeviceTokenFlag(flags, &cmd.SendCmd)
+	BindDataFlags(flags, &cmd.DataFlags)
+
+	_ = cobraCmd.MarkFlagRequired(AppIdFlag)
+	_ = cobraCmd.MarkFlagRequired(DeviceTokenFlag)
+
+	return cobraCmd
+}
+
+func (cmd *SendPushTypeCmd) Run() error {
+	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
+	notificationBuilder.SetPushType(cmd.PushType)
+
+	if cmd.DataFlags.IsSet() {
+		_, data, err := cmd.DataFlags.Read(cmd.IO.Stdin())
+		if err != nil {
+			return err
+		}
+
+		notificationBuilder.Merge(data)
+	}
+
+	if err := cmd.configureHeaders(notificationBuilder); err != nil {
+		return err
+	}
+
+	headers, content, err := notificationBuilder.Build()
+	if err != nil {
+		return err
+	}
+
+	return cmd.sendNotification(headers, content)
+}
@@ -5,16 +5,14 @@
 package send
 
 import (
+	"fmt"
+
 	"github.com/brannon/apnstool/apns"
 	"github.com/brannon/apnstool/cmdio"
 	"github.com/spf13/cobra"
 )
 
 const (
-	PriorityFlag    = "priority"
-	PriorityDefault = ""
-	PriorityDesc    = "value for 'apns-priority' header"
-
 	PushTypeFlag    = "push-type"
 	PushTypeDefault = ""
 	PushTypeDesc    = "value for 'apns-push-type' header"
@@ -22,10 +20,9 @@ const (
 
 type SendRawCmd struct {
 	SendCmd
+	DataFlags
 
-	DataString string
-	Priority   string
-	PushType   string
+	PushType string
 }
 
 func NewSendRawCommand() *cobra.Command {
@@ -44,29 +41,42 @@ func NewSendRawCommand() *cobra.Command {
 
 	flags := cobraCmd.Flags()
 	BindSendCommonFlags(flags, &cmd.SendCmd)
-	flags.StringVarP(&cmd.DataString, DataStringFlag, DataStringShortFlag, DataStringDefault, DataStringDesc)
-	flags.StringVar(&cmd.Priority, PriorityFlag, PriorityDefault, PriorityDesc)
+	BindDeviceTokenFlag(flags, &cmd.SendCmd)
+	BindDataFlags(flags, &cmd.DataFlags)
 	flags.StringVar(&cmd.PushType, PushTypeFlag, PushTypeDefault, PushTypeDesc)
 
 	_ = cobraCmd.MarkFlagRequired(AppIdFlag)
 	_ = cobraCmd.MarkFlagRequired(DeviceTokenFlag)
-	_ = cobraCmd.MarkFlagRequired(DataStringFlag)
 
 	return cobraCmd
 }
 
 func (cmd *SendRawCmd) Run() error {
-	headers := make(apns.Headers)
-
-	headers["apns-topic"] = cmd.AppId
+	if !cmd.DataFlags.IsSet() {
+		return fmt.Errorf("one of --%s or --%s is required", DataStringFlag, DataFileFlag)
+	}
 
-	if cmd.Priority != "" {
-		headers["apns-priority"] = cmd.Priority
+	content, _, err := cmd.DataFlags.Read(cmd.IO.Stdin())
+	if err != nil {
+		return err
 	}
 
+	// The content is sent exactly as given, so the builder is only used for
+	// headers and never infers a push type.
+	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
+
 	if cmd.PushType != "" {
-		headers["apns-push-type"] = cmd.PushType
+		notificationBuilder.SetPushType(cmd.PushType)
+	}
+
+	if err := cmd.configureHeaders(notificationBuilder); err != nil {
+		return err
+	}
+
+	headers, err := notificationBuilder.BuildHeaders()
+	if err != nil {
+		return err
 	}
 
-	return cmd.sendNotification(headers, []byte(cmd.DataString))
+	return cmd.sendNotification(headers, content)
 }
@@ -0,0 +1,127 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/brannon/apnstool/apns"
+	"github.com/brannon/apnstool/cmd/send"
+	"github.com/brannon/apnstool/cmdio"
+	"github.com/spf13/cobra"
+)
+
+const (
+	PushTypeFlag    = "push-type"
+	PushTypeDefault = ""
+	PushTypeDesc    = "value for 'apns-push-type' header (inferred from the content if not set)"
+)
+
+type ValidateCmd struct {
+	send.DataFlags
+
+	AppId    string
+	Headers  []string
+	Priority int
+	PushType string
+
+	IO cmdio.CmdIO
+}
+
+func GetCommand() *cobra.Command {
+	cmd := &ValidateCmd{}
+
+	cobraCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a notification against APNs rules without sending it",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.IO = cmdio.NewCmdIO(c.OutOrStdout())
+
+			return cmd.Run()
+		},
+	}
+
+	flags := cobraCmd.Flags()
+	send.BindDataFlags(flags, &cmd.DataFlags)
+	flags.StringVar(&cmd.AppId, send.AppIdFlag, send.AppIdDefault, send.AppIdDesc)
+	flags.StringArrayVarP(&cmd.Headers, send.HeaderFlag, send.HeaderShortFlag, nil, send.HeaderDesc)
+	flags.IntVar(&cmd.Priority, send.PriorityFlag, send.PriorityDefault, send.PriorityDesc)
+	flags.StringVar(&cmd.PushType, PushTypeFlag, PushTypeDefault, PushTypeDesc)
+	cmdio.BindEnv(flags, send.AppIdFlag)
+
+	return cobraCmd
+}
+
+func (cmd *ValidateCmd) Run() error {
+	if !cmd.DataFlags.IsSet() {
+		return fmt.Errorf("one of --%s or --%s is required", send.DataStringFlag, send.DataFileFlag)
+	}
+
+	content, data, err := cmd.DataFlags.Read(cmd.IO.Stdin())
+	if err != nil {
+		return cmdio.NewExitCodeError(cmdio.ExitPayloadError, err)
+	}
+
+	// The headers are derived the same way the send commands derive them; the
+	// content is validated exactly as given.
+	notificationBuilder := apns.NewNotificationBuilder(cmd.AppId)
+	notificationBuilder.Merge(data)
+
+	if cmd.PushType != "" {
+		notificationBuilder.SetPushType(cmd.PushType)
+	}
+
+	if cmd.Priority != 0 {
+		notificationBuilder.SetPriority(cmd.Priority)
+	}
+
+	for _, header := range cmd.Headers {
+		name, value, err := send.ParseHeader(header)
+		if err != nil {
+			return err
+		}
+		notificationBuilder.SetHeader(name, value)
+	}
+
+	headers, err := notificationBuilder.BuildHeaders()
+	if err != nil {
+		return err
+	}
+
+	issues := apns.Validate(headers, content)
+
+	output := &ValidateOutput{
+		Valid:  !issues.HasErrors(),
+		Issues: append(apns.ValidationIssues{}, issues...),
+	}
+	if err := cmd.IO.Output(output); err != nil {
+		return err
+	}
+
+	if !output.Valid {
+		return cmdio.NewExitCodeError(cmdio.ExitPayloadError, errors.New("notification is not valid"))
+	}
+
+	return nil
+}
+
+// ValidateOutput lists the problems found in a notification.
+type ValidateOutput struct {
+	Valid  bool                  `json:"valid" yaml:"valid"`
+	Issues apns.ValidationIssues `json:"issues" yaml:"issues"`
+}
+
+func (output *ValidateOutput) WriteText(w io.Writer) {
+	for _, issue := range output.Issues {
+		fmt.Fprintf(w, "%s\n", issue)
+	}
+
+	if output.Valid {
+		fmt.Fprint(w, "Notification is valid\n")
+	}
+}
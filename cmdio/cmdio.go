@@ -7,22 +7,40 @@ package cmdio
 import (
 	"fmt"
 	"io"
+	"os"
 )
 
 type CmdIO interface {
 	Out(s string)
 	Outf(format string, args ...interface{})
 
+	// Output writes a command's result in the format selected with
+	// --output. Values implementing TextWriter control their text form.
+	Output(v interface{}) error
+	OutputFormat() OutputFormat
+
+	// Log returns the writer for progress messages and verbose logs. It is
+	// stdout for text output and stderr otherwise, so that structured output
+	// can be piped to other tools.
+	Log() io.Writer
+
+	Stdin() io.Reader
 	Stdout() io.Writer
 }
 
 func NewCmdIO(stdout io.Writer) CmdIO {
 	return &cmdIO{
+		format: OutputFormat(outputFormat),
+		stderr: os.Stderr,
+		stdin:  os.Stdin,
 		stdout: stdout,
 	}
 }
 
 type cmdIO struct {
+	format OutputFormat
+	stderr io.Writer
+	stdin  io.Reader
 	stdout io.Writer
 }
 
@@ -34,6 +52,25 @@ func (cmdIO *cmdIO) Outf(format string, args ...interface{}) {
 	_, _ = io.WriteString(cmdIO.stdout, fmt.Sprintf(format, args...))
 }
 
+func (cmdIO *cmdIO) Output(v interface{}) error {
+	return writeOutput(cmdIO.stdout, cmdIO.format, v)
+}
+
+func (cmdIO *cmdIO) OutputFormat() OutputFormat {
+	return cmdIO.format
+}
+
+func (cmdIO *cmdIO) Log() io.Writer {
+	if cmdIO.format == OutputText {
+		return cmdIO.stdout
+	}
+	return cmdIO.stderr
+}
+
+func (cmdIO *cmdIO) Stdin() io.Reader {
+	return cmdIO.stdin
+}
+
 func (cmdIO *cmdIO) Stdout() io.Writer {
 	return cmdIO.stdout
 }
@@ -0,0 +1,64 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmdio
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	EnvPrefix = "APNSTOOL_"
+
+	envAnnotation = "apnstool_env"
+)
+
+// EnvName returns the environment variable bound to the named flag, such as
+// APNSTOOL_KEY_FILE for --key-file.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// BindEnv binds the named flags to their environment variables and adds the
+// variable names to the flags' help text. Use ApplyEnv to read the values.
+func BindEnv(flags *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			continue
+		}
+
+		envName := EnvName(name)
+		flag.Usage = fmt.Sprintf("%s [$%s]", flag.Usage, envName)
+		_ = flags.SetAnnotation(name, envAnnotation, []string{envName})
+	}
+}
+
+// ApplyEnv sets each flag bound with BindEnv that was not given on the
+// command line from its environment variable, if that is set.
+func ApplyEnv(flags *pflag.FlagSet) error {
+	var err error
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		envNames, ok := flag.Annotations[envAnnotation]
+		if err != nil || !ok || flag.Changed {
+			return
+		}
+
+		value, ok := os.LookupEnv(envNames[0])
+		if !ok {
+			return
+		}
+
+		if setErr := flags.Set(flag.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %s", value, envNames[0], setErr)
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmdio
+
+// Process exit codes returned by apnstool.
+const (
+	ExitOK             = 0
+	ExitError          = 1
+	ExitAuthError      = 3
+	ExitBadDeviceToken = 4
+	ExitPayloadError   = 5
+	ExitTransportError = 6
+	ExitRejected       = 7
+)
+
+// ExitCodeError is an error that causes the process to exit with a specific code.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func NewExitCodeError(code int, err error) *ExitCodeError {
+	return &ExitCodeError{
+		Code: code,
+		Err:  err,
+	}
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
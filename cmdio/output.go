@@ -0,0 +1,83 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmdio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+type OutputFormat string
+
+const (
+	OutputText OutputFormat = "text"
+	OutputJSON OutputFormat = "json"
+	OutputYAML OutputFormat = "yaml"
+)
+
+const (
+	OutputFlag      = "output"
+	OutputShortFlag = "o"
+	OutputDefault   = string(OutputText)
+	OutputDesc      = "output format: text, json or yaml"
+)
+
+// outputFormat is the format selected with --output, used by every CmdIO.
+var outputFormat = OutputDefault
+
+// BindOutputFlag binds --output, which selects the format of CmdIO.Output
+// for all commands.
+func BindOutputFlag(flags *pflag.FlagSet) {
+	flags.StringVarP(&outputFormat, OutputFlag, OutputShortFlag, OutputDefault, OutputDesc)
+	BindEnv(flags, OutputFlag)
+}
+
+// ValidateOutputFlag checks the value given with --output.
+func ValidateOutputFlag() error {
+	switch OutputFormat(outputFormat) {
+	case OutputText, OutputJSON, OutputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid --%s %q (must be %s, %s or %s)", OutputFlag, outputFormat, OutputText, OutputJSON, OutputYAML)
+}
+
+// SelectedOutputFormat returns the format selected with --output.
+func SelectedOutputFormat() OutputFormat {
+	return OutputFormat(outputFormat)
+}
+
+// TextWriter is implemented by command results that have a human-readable
+// form for --output text.
+type TextWriter interface {
+	WriteText(w io.Writer)
+}
+
+func writeOutput(w io.Writer, format OutputFormat, v interface{}) error {
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case OutputYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	if textWriter, ok := v.(TextWriter); ok {
+		textWriter.WriteText(w)
+		return nil
+	}
+
+	_, err := fmt.Fprintln(w, v)
+	return err
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Brannon Jones. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package config reads and writes the apnstool configuration file, which
+// stores named profiles of credentials and defaults.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/brannon/apnstool/cmd/auth"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	EnvironmentProduction = "production"
+	EnvironmentSandbox    = "sandbox"
+)
+
+type Config struct {
+	DefaultProfile string              `json:"default-profile,omitempty" yaml:"default-profile,omitempty"`
+	Profiles       map[string]*Profile `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+}
+
+// Profile bundles the credentials and defaults used by send commands.
+type Profile struct {
+	AppId           string               `json:"app-id,omitempty" yaml:"app-id,omitempty"`
+	Environment     string               `json:"environment,omitempty" yaml:"environment,omitempty"`
+	DeviceTokens    []string             `json:"device-tokens,omitempty" yaml:"device-tokens,omitempty"`
+	TokenAuth       auth.TokenAuth       `json:"token-auth,omitempty" yaml:"token-auth,omitempty"`
+	CertificateAuth auth.CertificateAuth `json:"certificate-auth,omitempty" yaml:"certificate-auth,omitempty"`
+}
+
+// DefaultPath returns the default location of the configuration file,
+// typically ~/.config/apnstool/config.yaml.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "apnstool", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path. A missing file yields an empty
+// configuration.
+func Load(path string) (*Config, error) {
+	config := &Config{}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	for name, profile := range config.Profiles {
+		if err := profile.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %s", path, name, err)
+		}
+	}
+
+	return config, nil
+}
+
+// Save writes the configuration to path, creating its directory if needed.
+// The file is only readable by the current user since it may hold secrets.
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// Profile returns the named profile, or the default profile if name is empty.
+// It returns nil if name is empty and there is no default profile.
+func (c *Config) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+		if name == "" {
+			return nil, nil
+		}
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the names of all profiles in sorted order.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Config) SetProfile(name string, profile *Profile) {
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	c.Profiles[name] = profile
+}
+
+func (c *Config) RemoveProfile(name string) error {
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("profile %q not found", name)
+	}
+
+	delete(c.Profiles, name)
+	if c.DefaultProfile == name {
+		c.DefaultProfile = ""
+	}
+	return nil
+}
+
+func (p *Profile) Validate() error {
+	switch p.Environment {
+	case "", EnvironmentProduction, EnvironmentSandbox:
+		return nil
+	}
+	return fmt.Errorf("invalid environment %q (must be %q or %q)", p.Environment, EnvironmentProduction, EnvironmentSandbox)
+}
@@ -8,4 +8,5 @@ require (
 	github.com/spf13/pflag v1.0.3
 	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 	golang.org/x/net v0.0.0-20191101175033-0deb6923b6d9
+	gopkg.in/yaml.v2 v2.4.0
 )